@@ -1,97 +1,165 @@
-package main
+// Package chade finds out what a character is from the way it was written down: it interprets the argument
+// (a character, an escape, a code point, some bytes...), decodes the bytes with every charset it knows and
+// encodes the resulting characters back in every notation and charset.
+//
+// Interpreters, decoders and encoders live in tables that can be extended with the Register functions.
+package chade
 
 import (
-	"os"
 	"strings"
-	"fmt"
 )
 
-func must(err os.Error) {
-	if err != nil {
-		panic(err)
-	}
+// Interpreted is one way of understanding the argument, either as a code point or as bytes to decode, with the
+// names of all the interpreters that understood it that way
+type Interpreted struct {
+	Interpreters []string
+	Char rune
+	Bytes []byte
+}
+
+func (in *Interpreted) same(char rune, bytes []byte) bool {
+	if (in.Bytes == nil) != (bytes == nil) { return false }
+	if bytes == nil { return in.Char == char }
+	return string(in.Bytes) == string(bytes)
 }
 
-func interpretInput(argument string) (string, int, []byte) {
+// Interpret runs every interpreter on argument (or only the one with id as, if as isn't empty), the results
+// are in the order of the interpreters table with the interpreters that agree grouped together
+func Interpret(argument string, as string) []*Interpreted {
+	r := []*Interpreted{}
 	for _, interpreter := range interpreters {
-		ok, char, bytes := interpreter.fn(argument)
-		if ok { return interpreter.name, char, bytes }
+		if (as != "") && (interpreter.Id() != as) { continue }
+		ok, char, bytes := interpreter.Interpret(argument)
+		if !ok { continue }
+		found := false
+		for _, in := range r {
+			if in.same(char, bytes) {
+				in.Interpreters = append(in.Interpreters, interpreter.Name())
+				found = true
+				break
+			}
+		}
+		if !found {
+			r = append(r, &Interpreted{ []string{ interpreter.Name() }, char, bytes })
+		}
 	}
-	return "", -1, nil
+	return r
 }
 
-func decodeInput(bytes []byte) (map[int][]string, map[string]string) {
-	r := make(map[int][]string)
-	reasons := make(map[string]string)
-	for _, decoder := range decoders {
-		ok, char, reason := decoder.fn(bytes)
-		if ok {
-			r[char] = append(r[char], decoder.name)
-		} else {
-			reasons[decoder.name] = reason
-		}
+// ValidInterpreterId tells if id is the id of an interpreter or of a text interpreter
+func ValidInterpreterId(id string) bool {
+	for _, interpreter := range interpreters {
+		if interpreter.Id() == id { return true }
+	}
+	for _, interpreter := range textInterpreters {
+		if interpreter.Id() == id { return true }
 	}
-	return r, reasons
+	return false
 }
 
-type EncodingResult struct {
-	name string
-	value string
+// Selection restricts the decoders and encoders that are run to the ones whose name matches one of its
+// filters, a nil Selection (or one without filters) selects everything. The filters given to NewSelection apply
+// to both, the ones given to RestrictDecoders and RestrictEncoders only to one of them.
+type Selection struct {
+	only []string
+	decoders []string
+	encoders []string
 }
 
-func runEncoders(character int) []EncodingResult {
-	r := make([]EncodingResult, 0)
-	for _, encoder := range encoders {
-		ok, value := encoder.fn(character)
-		if ok { r = append(r, EncodingResult{ encoder.name, value }) }
+func normalizeName(name string) string {
+	return strings.Replace(strings.ToLower(strings.TrimSpace(name)), "_", "-", -1)
+}
+
+func parseFilters(filters string) []string {
+	r := []string{}
+	for _, filter := range strings.Split(filters, ",") {
+		filter = normalizeName(filter)
+		if filter != "" { r = append(r, filter) }
 	}
 	return r
 }
 
-func runEncodersCL(character int, indent string) {
-	encodings := runEncoders(character)
-	for _, encoding := range encodings {
-		fmt.Printf("%sEncoded as %s:\t%s\n", indent, encoding.name, encoding.value)
+// a filter matches a name if it's equal to it or to the part of it before the description in parenthesis,
+// "iso-8859-1" matches "ISO-8859-1 (latin1)"
+func matchFilters(filters []string, name string) bool {
+	if len(filters) == 0 { return true }
+	name = normalizeName(name)
+	for _, filter := range filters {
+		if name == filter { return true }
+		if strings.HasPrefix(name, filter + " (") { return true }
 	}
+	return false
 }
 
-func main() {
-	switch os.Args[1] {
-	case "test-unidecode":
-		testUnidecode()
-		return
-	case "test-jis":
-		testJIS()
-		return
-	}
-	
-	InitUnicodeData()
-	InitHTMLEntities()
-	
-	argument :=  strings.TrimSpace(strings.Join(os.Args[1:], " "))
-	fmt.Printf("Argument: [%s]\n", argument)
-
-	name, character, bytes := interpretInput(argument)
-
-	if name == "" {
-		fmt.Printf("Could not understand input\n")
-		return;
-	}
+// NewSelection parses a comma separated list of names, for example "utf-8,shift-jis"
+func NewSelection(filters string) *Selection {
+	return &Selection{ only: parseFilters(filters) }
+}
 
-	fmt.Printf("Interpreted as %s\n", name)
-
-	if bytes == nil {
-		fmt.Printf("\n")
-		runEncodersCL(character, "")
-	} else {
-		characters, reasons := decodeInput(bytes)
-		for character, decoderNames := range characters {
-			fmt.Printf("Decoded as %v:\n\n", decoderNames)
-			runEncodersCL(character, "\t")
-			fmt.Printf("\n")
-		}
-		for decoderName, reason := range reasons {
-			fmt.Printf("Can not be decoded as %s because %s\n", decoderName, reason);
+// RestrictDecoders adds a comma separated list of names that only applies to decoders
+func (sel *Selection) RestrictDecoders(filters string) {
+	sel.decoders = parseFilters(filters)
+}
+
+// RestrictEncoders adds a comma separated list of names that only applies to encoders
+func (sel *Selection) RestrictEncoders(filters string) {
+	sel.encoders = parseFilters(filters)
+}
+
+func (sel *Selection) Accepts(name string) bool {
+	if sel == nil { return true }
+	return matchFilters(sel.only, name)
+}
+
+func (sel *Selection) AcceptsDecoder(name string) bool {
+	if sel == nil { return true }
+	return matchFilters(sel.only, name) && matchFilters(sel.decoders, name)
+}
+
+func (sel *Selection) AcceptsEncoder(name string) bool {
+	if sel == nil { return true }
+	return matchFilters(sel.only, name) && matchFilters(sel.encoders, name)
+}
+
+func (sel *Selection) String() string {
+	if sel == nil { return "everything" }
+	r := []string{}
+	if len(sel.only) > 0 { r = append(r, strings.Join(sel.only, ",")) }
+	if len(sel.decoders) > 0 { r = append(r, "decoders " + strings.Join(sel.decoders, ",")) }
+	if len(sel.encoders) > 0 { r = append(r, "encoders " + strings.Join(sel.encoders, ",")) }
+	if len(r) == 0 { return "everything" }
+	return strings.Join(r, ", ")
+}
+
+// Decode runs the decoders accepted by sel on bytes, it returns the names of the decoders that succeeded indexed
+// by character and the errors of the ones that failed indexed by decoder name
+func Decode(bytes []byte, sel *Selection) (map[rune][]string, map[string]*DecodeError) {
+	r := make(map[rune][]string)
+	errors := make(map[string]*DecodeError)
+	for _, decoder := range decoders {
+		if !sel.AcceptsDecoder(decoder.Name()) { continue }
+		char, err := decoder.Decode(bytes)
+		if err == nil {
+			r[char] = append(r[char], decoder.Name())
+		} else {
+			errors[decoder.Name()] = err
 		}
 	}
-}
\ No newline at end of file
+	return r, errors
+}
+
+type EncodingResult struct {
+	Name string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Encode runs the encoders accepted by sel on character, in the order of the encoders table
+func Encode(character rune, sel *Selection) []EncodingResult {
+	r := make([]EncodingResult, 0)
+	for _, encoder := range encoders {
+		if !sel.AcceptsEncoder(encoder.Name()) { continue }
+		ok, value := encoder.Encode(character)
+		if ok { r = append(r, EncodingResult{ encoder.Name(), value }) }
+	}
+	return r
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"os"
+	"strings"
+)
+
+// runBatch analyzes every line of the file named in args (or of standard input) and writes a report with one
+// row for each character a line could be, under every interpretation
+func runBatch(args []string, o *options) {
+	sel := o.selection()
+	var file *os.File = os.Stdin
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Batch mode reads only one file\n")
+		os.Exit(1)
+	}
+	if len(args) == 1 {
+		var err error
+		file, err = os.Open(args[0])
+		must(err)
+		defer file.Close()
+	}
+
+	var emit func(lineno int, r *chade.Report)
+	var done func()
+
+	switch o.format {
+	case "tsv", "":
+		out := bufio.NewWriter(os.Stdout)
+		emit = func(lineno int, r *chade.Report) {
+			for _, row := range batchRows(lineno, r, sel, o.onlyMatching) {
+				for i := range row { row[i] = sanitizeTSV(row[i]) }
+				fmt.Fprintf(out, "%s\n", strings.Join(row, "\t"))
+			}
+		}
+		done = func() { out.Flush() }
+		fmt.Fprintf(out, "%s\n", strings.Join(batchHeader(sel), "\t"))
+	case "csv":
+		out := csv.NewWriter(os.Stdout)
+		emit = func(lineno int, r *chade.Report) {
+			for _, row := range batchRows(lineno, r, sel, o.onlyMatching) {
+				must(out.Write(row))
+			}
+		}
+		done = func() { out.Flush() }
+		must(out.Write(batchHeader(sel)))
+	case "jsonl", "json":
+		out := bufio.NewWriter(os.Stdout)
+		emit = func(lineno int, r *chade.Report) {
+			line, err := json.Marshal(batchLine{ lineno, r })
+			must(err)
+			out.Write(line)
+			out.WriteString("\n")
+		}
+		done = func() { out.Flush() }
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown batch format %s (can be tsv, csv or jsonl)\n", o.format)
+		os.Exit(1)
+	}
+
+	// with --quiet nothing is printed, the exit status tells if any line matched
+	if o.quiet {
+		emit = func(lineno int, r *chade.Report) {}
+		done = func() {}
+	}
+
+	in := bufio.NewReader(file)
+	lineno := 0
+	matched := false
+	for {
+		line, err := in.ReadString('\n')
+		if (err != nil) && (len(line) == 0) { break }
+		lineno++
+		line = strings.TrimSpace(line)
+		if line == "" { continue }
+		report := chade.Analyze(line, o.as, sel)
+		if reportMatched(report) { matched = true }
+		if o.onlyMatching { removeRejections(report) }
+		emit(lineno, report)
+	}
+
+	done()
+	if o.quiet && !matched { os.Exit(1) }
+}
+
+type batchLine struct {
+	Line int `json:"line"`
+	Report *chade.Report `json:"report"`
+}
+
+// the columns are fixed: one for each selected encoder, empty when the encoder doesn't apply to the character
+func batchHeader(sel *chade.Selection) []string {
+	r := []string{ "Line", "Argument", "Interpreter", "Decoders", "Codepoint" }
+	for _, encoder := range batchEncoders(sel) {
+		r = append(r, encoder.Name())
+	}
+	return r
+}
+
+// batchEncoders are the selected encoders except Codepoint, that has its own column
+func batchEncoders(sel *chade.Selection) []chade.Encoder {
+	r := []chade.Encoder{}
+	for _, encoder := range chade.Encoders() {
+		if (encoder.Name() == "Codepoint") || !sel.AcceptsEncoder(encoder.Name()) { continue }
+		r = append(r, encoder)
+	}
+	return r
+}
+
+// with onlyMatching the arguments that weren't understood and the interpretations that no decoder accepted
+// have no row
+func batchRows(lineno int, r *chade.Report, sel *chade.Selection, onlyMatching bool) [][]string {
+	ncols := len(batchHeader(sel))
+
+	if r.Interpreter == "" {
+		if onlyMatching { return [][]string{} }
+		row := make([]string, ncols)
+		copy(row, []string{ fmt.Sprintf("%d", lineno), r.Argument })
+		return [][]string{ row }
+	}
+
+	rows := [][]string{}
+	for _, it := range r.Interpretations() {
+		prefix := []string{ fmt.Sprintf("%d", lineno), r.Argument, it.Interpreter }
+		if (len(it.Decodings) == 0) && !onlyMatching {
+			row := make([]string, ncols)
+			copy(row, prefix)
+			rows = append(rows, row)
+		}
+		for _, decoding := range it.Decodings {
+			row := make([]string, ncols)
+			copy(row, prefix)
+			row[3] = strings.Join(decoding.Decoders, ", ")
+			row[4] = fmt.Sprintf("U+%04X", decoding.Codepoint)
+			values := make(map[string]string)
+			for _, encoding := range decoding.Encodings {
+				values[encoding.Name] = encoding.Value
+			}
+			for i, encoder := range batchEncoders(sel) {
+				row[5+i] = values[encoder.Name()]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func sanitizeTSV(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.Replace(s, "\t", " ", -1)
+	return strings.Replace(s, "\n", "; ", -1)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"html"
+	"os"
+	"strconv"
+)
+
+const (
+	CELL_UNDEFINED rune = -1
+	CELL_LEAD rune = -2 // first byte of a multibyte sequence
+)
+
+// chartCells decodes the 256 sequences obtained appending a byte to prefix
+func chartCells(decoder chade.Decoder, prefix []byte) []rune {
+	cells := make([]rune, 256)
+	for b := 0; b < 256; b++ {
+		in := append(append([]byte{}, prefix...), byte(b))
+		if char, err := decoder.Decode(in); err == nil {
+			cells[b] = char
+			continue
+		}
+		cells[b] = CELL_UNDEFINED
+		if len(prefix) > 0 { continue }
+		for b2 := 0x20; b2 < 256; b2++ {
+			if _, err := decoder.Decode([]byte{ byte(b), byte(b2) }); err == nil {
+				cells[b] = CELL_LEAD
+				break
+			}
+		}
+	}
+	return cells
+}
+
+// chart prints the characters encoded by every byte of charset, or by every byte following lead if lead isn't -1
+func chart(charset string, lead int, htmlOutput bool) {
+	decoder, ok := chade.FindDecoder(charset)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown charset %s\n", charset)
+		os.Exit(1)
+	}
+
+	prefix := []byte{}
+	title := decoder.Name()
+	if lead >= 0 {
+		prefix = append(prefix, byte(lead))
+		title = fmt.Sprintf("%s, lead byte %02X", decoder.Name(), lead)
+	}
+
+	cells := chartCells(decoder, prefix)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if htmlOutput {
+		chartHTML(out, title, cells)
+	} else {
+		chartText(out, title, cells)
+	}
+}
+
+func chartText(out *bufio.Writer, title string, cells []rune) {
+	fmt.Fprintf(out, "%s\n\n   ", title)
+	for col := 0; col < 16; col++ {
+		fmt.Fprintf(out, "   _%X ", col)
+	}
+	fmt.Fprintf(out, "\n")
+
+	for row := 0; row < 16; row++ {
+		fmt.Fprintf(out, "\n%X_ ", row)
+		for col := 0; col < 16; col++ {
+			char := cells[row*16 + col]
+			switch char {
+			case CELL_UNDEFINED: fmt.Fprintf(out, "  %-4s", "--")
+			case CELL_LEAD: fmt.Fprintf(out, "  %-4s", "lead")
+			default: fmt.Fprintf(out, "  %-4s", dumpChar(char))
+			}
+		}
+		fmt.Fprintf(out, "\n   ")
+		for col := 0; col < 16; col++ {
+			char := cells[row*16 + col]
+			if char < 0 {
+				fmt.Fprintf(out, "      ")
+			} else {
+				fmt.Fprintf(out, "  %04X", char)
+			}
+		}
+		fmt.Fprintf(out, "\n")
+	}
+
+	fmt.Fprintf(out, "\n-- undefined, lead: first byte of a multibyte sequence\n")
+}
+
+func chartHTML(out *bufio.Writer, title string, cells []rune) {
+	title = html.EscapeString(title)
+	fmt.Fprintf(out, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", title)
+	fmt.Fprintf(out, "<style>\ntd, th { border: 1px solid #ccc; text-align: center; width: 3em; height: 3em; }\n")
+	fmt.Fprintf(out, "td small { display: block; color: #666; font-size: 0.7em; }\n.undefined { background: #eee; }\n.lead { background: #def; }\n</style>\n")
+	fmt.Fprintf(out, "</head>\n<body>\n<h1>%s</h1>\n<table>\n<tr><th></th>", title)
+	for col := 0; col < 16; col++ {
+		fmt.Fprintf(out, "<th>_%X</th>", col)
+	}
+	fmt.Fprintf(out, "</tr>\n")
+
+	for row := 0; row < 16; row++ {
+		fmt.Fprintf(out, "<tr><th>%X_</th>", row)
+		for col := 0; col < 16; col++ {
+			char := cells[row*16 + col]
+			switch char {
+			case CELL_UNDEFINED: fmt.Fprintf(out, "<td class=\"undefined\"></td>")
+			case CELL_LEAD: fmt.Fprintf(out, "<td class=\"lead\">lead</td>")
+			default: fmt.Fprintf(out, "<td title=\"%s\">%s<small>%04X</small></td>", html.EscapeString(dumpName(char)), html.EscapeString(dumpChar(char)), char)
+			}
+		}
+		fmt.Fprintf(out, "</tr>\n")
+	}
+
+	fmt.Fprintf(out, "</table>\n</body>\n</html>\n")
+}
+
+func runChart(fs *flag.FlagSet, o *options, args []string) { chart(parseChartArgs(fs, args)) }
+
+// parseChartArgs reads the arguments of "chade chart <charset> [--lead <byte>] [--html]"
+func parseChartArgs(fs *flag.FlagSet, args []string) (charset string, lead int, htmlOutput bool) {
+	leadByte := fs.String("lead", "", "chart the second bytes of the sequences that start with this byte (81 or 0x81)")
+	fs.BoolVar(&htmlOutput, "html", false, "write an HTML table")
+	args = parseFlags(fs, args)
+	if len(args) != 1 { usageError(fs) }
+
+	lead = -1
+	if *leadByte != "" {
+		n, err := strconv.ParseUint(*leadByte, 0, 8)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Bad lead byte %s\n", *leadByte)
+			os.Exit(1)
+		}
+		lead = int(n)
+	}
+	return args[0], lead, htmlOutput
+}
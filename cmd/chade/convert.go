@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"github.com/aarzilli/chade/internal/iconv"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// what convert does with a character it can't decode from the input or encode in the output
+const (
+	ON_ERROR_FAIL = "fail"
+	ON_ERROR_REPLACE = "replace" // U+FFFD, or ? if the output charset doesn't have it
+	ON_ERROR_QUESTION = "question" // ?
+	ON_ERROR_HTML = "html" // HTML decimal character reference
+	ON_ERROR_ESCAPE = "escape" // \uXXXX, or \xXX for bytes that couldn't be decoded
+)
+
+// convert reads and decodes the input convertChunk bytes at a time, the decoded text is encoded encodeSlice
+// bytes at a time
+const (
+	convertChunk = 64*1024
+	encodeSlice = 1024
+)
+
+type converter struct {
+	from chade.Decoder
+	to chade.Encoder
+	decoder *iconv.Converter // from the input charset to UTF-8, nil if iconv doesn't know the input charset
+	stream chade.StreamDecoder // used when decoder is nil
+	encoder *iconv.Converter // from UTF-8 to the output charset, nil for a ByteEncoder
+	onError string
+	replacement string // for ON_ERROR_REPLACE
+	out *bufio.Writer
+	substitutions int
+}
+
+// convert reads inPath as from and writes it to outPath as to, both can be "-" for standard input and output.
+// The input goes through iconv a chunk at a time, with one conversion open in each direction, characters are
+// looked at one by one only where iconv stops. Every substitution is reported on standard error as soon as it
+// happens, so that files of any size can be converted.
+func convert(from, to, onError, inPath, outPath string) {
+	c := &converter{ onError: onError }
+	var ok bool
+
+	if c.from, ok = chade.FindDecoder(from); !ok {
+		fmt.Fprintf(os.Stderr, "Unknown input charset %s\n", from)
+		os.Exit(1)
+	}
+	if c.to, ok = chade.FindEncoder(to); !ok {
+		fmt.Fprintf(os.Stderr, "Unknown output charset %s\n", to)
+		os.Exit(1)
+	}
+
+	switch onError {
+	case ON_ERROR_FAIL, ON_ERROR_REPLACE, ON_ERROR_QUESTION, ON_ERROR_HTML, ON_ERROR_ESCAPE:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown error policy %s (can be fail, replace, question, html or escape)\n", onError)
+		os.Exit(1)
+	}
+
+	// the stateful charsets go through their StreamDecoder, that knows where the errors are
+	if (c.from.Charset() != "") && !chade.Stateful(c.from) {
+		if dec, err := iconv.Open("UTF-8", c.from.Charset()); err == nil {
+			c.decoder = dec
+			defer dec.Close()
+		}
+	}
+	if c.decoder == nil { c.stream = chade.NewStreamDecoder(c.from) }
+	if _, raw := c.to.(chade.ByteEncoder); !raw {
+		if enc, err := iconv.Open(c.to.Charset(), "UTF-8"); err == nil {
+			c.encoder = enc
+			defer enc.Close()
+		}
+	}
+	c.replacement = "?"
+	if ok, _ := chade.EncodeRaw(c.to, utf8.RuneError); ok { c.replacement = string(utf8.RuneError) }
+
+	infile := os.Stdin
+	if inPath != "-" {
+		var err error
+		infile, err = os.Open(inPath)
+		must(err)
+		defer infile.Close()
+	}
+
+	outfile := os.Stdout
+	if outPath != "-" {
+		var err error
+		outfile, err = os.Create(outPath)
+		must(err)
+		defer outfile.Close()
+	}
+
+	c.out = bufio.NewWriter(outfile)
+	defer c.out.Flush()
+
+	// buf[:len(buf)] is the input from offset that hasn't been converted yet, a chunk and what was left
+	// over of the previous one: the beginning of a sequence that continues in the next chunk
+	buf := make([]byte, 0, convertChunk + chade.MaxStepLen)
+	offset := 0
+	eof := false
+	for !eof || (len(buf) > 0) {
+		if !eof {
+			n, err := io.ReadFull(infile, buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+			if (err == io.EOF) || (err == io.ErrUnexpectedEOF) {
+				eof = true
+			} else {
+				must(err)
+			}
+		}
+
+		var n int
+		if c.decoder != nil {
+			n = c.convertChunk(buf, offset, eof)
+		} else {
+			n = c.convertChars(buf, offset, eof)
+		}
+		buf = buf[:copy(buf, buf[n:])]
+		offset += n
+	}
+
+	if c.stream != nil {
+		if err := c.stream.End(); err != nil { c.invalid(nil, offset, err) }
+	}
+	if c.encoder != nil { c.out.Write(c.encoder.Reset()) }
+
+	if c.substitutions > 0 {
+		fmt.Fprintf(os.Stderr, "%d substitutions\n", c.substitutions)
+	}
+}
+
+// convertChunk decodes buf with iconv and returns how many bytes were used, a sequence cut at the end of buf is
+// kept for the next chunk unless eof is set
+func (c *converter) convertChunk(buf []byte, offset int, eof bool) int {
+	pos := 0
+	for pos < len(buf) {
+		text, n, err := c.decoder.Convert(buf[pos:])
+		c.encode(text, buf[pos:pos+n], offset+pos)
+		pos += n
+
+		if (err == iconv.ErrIncomplete) && !eof { return pos }
+		if err != nil {
+			decodeErr := c.decodeError(buf[pos:])
+			length := chade.InvalidLength(decodeErr)
+			if length > len(buf) - pos { length = len(buf) - pos }
+			c.invalid(buf[pos:pos+length], offset+pos, decodeErr)
+			pos += length
+		}
+	}
+	return pos
+}
+
+// convertChars decodes buf a character at a time with the decoder of the input charset, for the stateful
+// charsets and the ones iconv doesn't know
+func (c *converter) convertChars(buf []byte, offset int, eof bool) int {
+	pos := 0
+	for pos < len(buf) {
+		if !eof && (len(buf) - pos < chade.MaxStepLen) { break }
+		char, length, _, err := c.stream.Step(buf[pos:])
+		switch {
+		case err != nil:
+			c.invalid(buf[pos:pos+length], offset+pos, err)
+		case char >= 0:
+			c.encode([]byte(string(char)), buf[pos:pos+length], offset+pos)
+		}
+		pos += length
+	}
+	return pos
+}
+
+// decodeError explains why iconv stopped at the beginning of in
+func (c *converter) decodeError(in []byte) *chade.DecodeError {
+	if _, _, err := chade.DecodeStep(c.from, in); err != nil { return err }
+	return &chade.DecodeError{ Kind: chade.DECODE_UNMAPPED, Message: "Rejected by iconv" }
+}
+
+// invalid substitutes the bytes bad, that couldn't be decoded. bad is empty when the problem isn't about bytes but
+// about where they are, a UTF-7 base64 run that ends badly for example.
+func (c *converter) invalid(bad []byte, offset int, err *chade.DecodeError) {
+	if len(bad) == 0 {
+		c.substitute(offset, -1, bad, fmt.Sprintf("invalid %s: %s", c.from.Name(), err))
+		return
+	}
+	c.substitute(offset, -1, bad, fmt.Sprintf("%s can not be decoded as %s: %s", dumpBytes(bad), c.from.Name(), err))
+}
+
+// encode writes text, decoded from input, in the output charset
+func (c *converter) encode(text []byte, input []byte, offset int) {
+	offsets := &inputOffsets{ input: input }
+	defer offsets.close()
+
+	chars := 0
+	for len(text) > 0 {
+		if c.encoder != nil {
+			// glibc goes through the whole input again to find where a conversion failed, small slices keep
+			// the files with many characters the output charset doesn't have from being quadratic
+			end := len(text)
+			if end > encodeSlice {
+				end = encodeSlice
+				for (end < len(text)) && !utf8.RuneStart(text[end]) { end++ }
+			}
+			out, n, err := c.encoder.Convert(text[:end])
+			c.out.Write(out)
+			if (err == nil) && (n == len(text)) { return }
+			chars += utf8.RuneCount(text[:n])
+			text = text[n:]
+			if err == nil { continue }
+			// text is valid UTF-8, iconv stopped at a character the output charset doesn't have
+		}
+
+		char, size := utf8.DecodeRune(text)
+		if ok, out := c.encodeChar(char); ok {
+			c.out.Write(out)
+		} else {
+			if (chars > 0) && (offsets.dec == nil) { offsets.dec, _ = iconv.Open("UTF-8", c.from.Charset()) }
+			c.substitute(offset + offsets.find(chars), char, nil, fmt.Sprintf("U+%04X %s can not be encoded as %s", char, dumpName(char), c.to.Name()))
+		}
+		chars++
+		text = text[size:]
+	}
+}
+
+func (c *converter) encodeChar(char rune) (bool, []byte) {
+	if c.encoder == nil {
+		ok, out := chade.EncodeRaw(c.to, char)
+		return ok, []byte(out)
+	}
+	out, _, err := c.encoder.Convert([]byte(string(char)))
+	return err == nil, out
+}
+
+// inputOffsets finds where the characters decoded from input start in input, it's only needed to report errors.
+// It decodes input again a byte at a time, starting from where the previous character was found.
+type inputOffsets struct {
+	input []byte
+	dec *iconv.Converter
+	pos int // of the first byte of the character number chars
+	chars int
+}
+
+func (o *inputOffsets) find(chars int) int {
+	if o.dec == nil { return 0 }
+	end := o.pos + 1
+	for (o.chars < chars) && (end <= len(o.input)) {
+		text, n, _ := o.dec.Convert(o.input[o.pos:end])
+		if n == 0 {
+			end++
+			continue
+		}
+		if o.chars + utf8.RuneCount(text) > chars { break }
+		o.chars += utf8.RuneCount(text)
+		o.pos += n
+		end = o.pos + 1
+	}
+	return o.pos
+}
+
+func (o *inputOffsets) close() {
+	if o.dec != nil { o.dec.Close() }
+}
+
+// substitute writes the replacement for a character that couldn't be converted, char is -1 if the problem
+// was decoding the bytes bad
+func (c *converter) substitute(offset int, char rune, bad []byte, problem string) {
+	if c.onError == ON_ERROR_FAIL {
+		c.out.Flush()
+		fmt.Fprintf(os.Stderr, "Offset %08X: %s\n", offset, problem)
+		os.Exit(1)
+	}
+
+	replacement := "?"
+	switch c.onError {
+	case ON_ERROR_REPLACE:
+		replacement = c.replacement
+	case ON_ERROR_HTML:
+		if char >= 0 {
+			replacement = chade.HTMLDecimalReference(char)
+		} else {
+			replacement = chade.HTMLDecimalReference(utf8.RuneError)
+		}
+	case ON_ERROR_ESCAPE:
+		if char >= 0 {
+			replacement = chade.EscapeUniversal(char)
+		} else {
+			replacement = ""
+			for _, b := range bad {
+				replacement += fmt.Sprintf("\\x%02X", b)
+			}
+		}
+	}
+
+	// the replacement is made of characters that every charset can encode
+	for _, rc := range replacement {
+		ok, out := c.encodeChar(rc)
+		if !ok {
+			ok, out = c.encodeChar('?')
+		}
+		if ok { c.out.Write(out) }
+	}
+
+	c.substitutions++
+	fmt.Fprintf(os.Stderr, "Offset %08X: %s, replaced with %s\n", offset, problem, replacement)
+}
+
+func runConvert(fs *flag.FlagSet, o *options, args []string) { convert(parseConvertArgs(fs, args)) }
+
+// parseConvertArgs reads the arguments of "chade convert --from <charset> --to <charset> [--on-error <policy>] <in> <out>"
+func parseConvertArgs(fs *flag.FlagSet, args []string) (from, to, onError, inPath, outPath string) {
+	fs.StringVar(&from, "from", "", "charset of the input")
+	fs.StringVar(&to, "to", "", "charset of the output")
+	fs.StringVar(&onError, "on-error", ON_ERROR_FAIL, "what to do with the characters that can't be converted: fail, replace, question, html or escape")
+	args = parseFlags(fs, args)
+	if (from == "") || (to == "") || (len(args) != 2) { usageError(fs) }
+	return from, to, onError, args[0], args[1]
+}
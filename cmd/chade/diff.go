@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"github.com/aarzilli/chade/internal/iconv"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// diffCharset is one side of a comparison, the charsets in the tables use their own decoder, the others are
+// compared through iconv so that any charset iconv knows can be used even if it isn't in the tables
+type diffCharset struct {
+	label string
+	dec func([]byte) (rune, *chade.DecodeError)
+	enc func(rune) (bool, string)
+}
+
+func makeDiffCharset(charset string) diffCharset {
+	if decoder, ok := chade.FindDecoder(charset); ok {
+		return diffCharset{ decoder.Name(), decoder.Decode, diffEncoder(decoder) }
+	}
+	// iconv takes an empty name for the charset of the locale, and ignores what comes after a ',' or a '/'
+	if strings.TrimSpace(charset[:strings.IndexAny(charset + ",", ",/")]) == "" { unknownDiffCharset(charset) }
+	cd, err := iconv.Open("UTF-8", charset)
+	if err != nil { unknownDiffCharset(charset) }
+	cd.Close()
+	return diffCharset{ charset, chade.MakeDecIconv(charset), chade.MakeEncIconv(charset, false) }
+}
+
+func unknownDiffCharset(charset string) {
+	fmt.Fprintf(os.Stderr, "Unknown charset %s\n", charset)
+	os.Exit(1)
+}
+
+// diffEncoder returns the bytes of a character written by the encoder that goes with decoder, in hexadecimal. The
+// ASCII characters that Encode leaves out are compared too.
+func diffEncoder(decoder chade.Decoder) func(rune) (bool, string) {
+	encoder, ok := chade.FindEncoder(decoder.Name())
+	if !ok {
+		if decoder.Charset() != "" { return chade.MakeEncIconv(decoder.Charset(), false) }
+		return func(char rune) (bool, string) { return false, "" }
+	}
+	return func(char rune) (bool, string) {
+		ok, out := chade.EncodeRaw(encoder, char)
+		if !ok { return false, "" }
+		return true, chade.EncodeBytes(out)
+	}
+}
+
+func (dc diffCharset) describeByte(b byte) string {
+	char, err := dc.dec([]byte{ b })
+	if err != nil { return "undefined" }
+	return fmt.Sprintf("U+%04X %s", char, dumpName(char))
+}
+
+// charsetDiff prints the bytes that decode differently in the two charsets, if codepoints is true it also prints
+// the code points that only one of the two can encode
+func charsetDiff(charsetA, charsetB string, codepoints bool) {
+	a, b := makeDiffCharset(charsetA), makeDiffCharset(charsetB)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "Bytes decoded differently:\n\n%-4s  %-40s  %s\n", "Byte", a.label, b.label)
+	count := 0
+	for i := 0; i < 256; i++ {
+		charA, errA := a.dec([]byte{ byte(i) })
+		charB, errB := b.dec([]byte{ byte(i) })
+		if ((errA == nil) == (errB == nil)) && ((errA != nil) || (charA == charB)) { continue }
+		fmt.Fprintf(out, "%02X    %-40s  %s\n", i, a.describeByte(byte(i)), b.describeByte(byte(i)))
+		count++
+	}
+	fmt.Fprintf(out, "\n%d bytes differ\n", count)
+
+	if !codepoints { return }
+
+	fmt.Fprintf(out, "\nCode points encoded by only one of the two:\n\n%-9s  %-12s  %-12s  %s\n", "Codepoint", a.label, b.label, "Name")
+	count = 0
+	for char := rune(0); char <= unicode.MaxRune; char++ {
+		if chade.IsSurrogate(char) { continue }
+		okA, bytesA := a.enc(char)
+		okB, bytesB := b.enc(char)
+		if okA == okB { continue }
+		if !okA { bytesA = "-" }
+		if !okB { bytesB = "-" }
+		fmt.Fprintf(out, "U+%-7s  %-12s  %-12s  %s\n", fmt.Sprintf("%04X", char), bytesA, bytesB, dumpName(char))
+		count++
+	}
+	fmt.Fprintf(out, "\n%d code points differ\n", count)
+}
+
+func runDiff(fs *flag.FlagSet, o *options, args []string) { charsetDiff(parseDiffArgs(fs, args)) }
+
+// parseDiffArgs reads the arguments of "chade diff <charset> <charset> [--codepoints]"
+func parseDiffArgs(fs *flag.FlagSet, args []string) (charsetA, charsetB string, codepoints bool) {
+	fs.BoolVar(&codepoints, "codepoints", false, "also print the code points that only one of the two can encode")
+	args = parseFlags(fs, args)
+	if len(args) != 2 { usageError(fs) }
+	return args[0], args[1], codepoints
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"os"
+	"strings"
+)
+
+// dump prints every character of the file at path decoded as charset, one per line with its offset, bytes, code
+// point and name. The file is read as a stream.
+func dump(charset string, path string) {
+	decoder, ok := chade.FindDecoder(charset)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown charset %s\n", charset)
+		os.Exit(1)
+	}
+
+	file, err := os.Open(path)
+	must(err)
+	defer file.Close()
+
+	in := bufio.NewReader(file)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "Dump of %s as %s\n\n", path, decoder.Name())
+	fmt.Fprintf(out, "%-8s  %-12s  %-4s  %-9s  %s\n", "Offset", "Bytes", "Char", "Codepoint", "Name")
+
+	offset := 0
+	invalid := 0
+	stream := chade.NewStreamDecoder(decoder)
+	for {
+		buf, _ := in.Peek(chade.MaxStepLen)
+		if len(buf) == 0 { break }
+
+		char, length, shift, err := stream.Step(buf)
+		switch {
+		case err != nil:
+			fmt.Fprintf(out, "%08X  %-12s  !! invalid %s: %s\n", offset, dumpBytes(buf[:length]), decoder.Name(), err)
+			invalid += length
+		case char < 0:
+			// escape sequences and shifts of the stateful charsets
+			fmt.Fprintf(out, "%08X  %-12s  %s\n", offset, dumpBytes(buf[:length]), shift)
+		default:
+			fmt.Fprintf(out, "%08X  %-12s  %-4s  U+%-7s  %s\n", offset, dumpBytes(buf[:length]), dumpChar(char), fmt.Sprintf("%04X", char), dumpName(char))
+		}
+
+		in.Discard(length)
+		offset += length
+	}
+	if err := stream.End(); err != nil {
+		fmt.Fprintf(out, "%08X  %-12s  !! invalid %s: %s\n", offset, "", decoder.Name(), err)
+	}
+
+	fmt.Fprintf(out, "\n%d bytes, %d invalid\n", offset, invalid)
+}
+
+func dumpBytes(in []byte) string {
+	r := make([]string, len(in))
+	for i := range in {
+		r[i] = fmt.Sprintf("%02X", in[i])
+	}
+	return strings.Join(r, " ")
+}
+
+func dumpChar(char rune) string {
+	if ok, s := chade.EncCharacter(char); ok { return s }
+	return "."
+}
+
+func dumpName(char rune) string {
+	if (char < 0) || (int(char) >= len(chade.UnicodeDataFile)) || (chade.UnicodeDataFile[char] == nil) { return "" }
+	return chade.UnicodeDataFile[char].Name
+}
+
+func runDump(fs *flag.FlagSet, o *options, args []string) { dump(parseDumpArgs(fs, args)) }
+
+// parseDumpArgs reads the arguments of "chade dump --charset <charset> <file>"
+func parseDumpArgs(fs *flag.FlagSet, args []string) (charset string, path string) {
+	fs.StringVar(&charset, "charset", "", "charset of the file")
+	args = parseFlags(fs, args)
+	if (charset == "") || (len(args) != 1) { usageError(fs) }
+	return charset, args[0]
+}
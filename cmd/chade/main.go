@@ -0,0 +1,235 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"os"
+	"strings"
+)
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// options are the flags of the commands that look characters up, they can be given before the command or after it
+type options struct {
+	format string
+	only string
+	decoders string
+	encoders string
+	as string
+	onlyMatching bool
+	quiet bool
+}
+
+// define adds the options to fs, the current values are the defaults so that the options given before the
+// command are kept
+func (o *options) define(fs *flag.FlagSet) {
+	fs.StringVar(&o.format, "format", o.format, "output format: text or json (tsv, csv or jsonl with --batch)")
+	fs.StringVar(&o.only, "only", o.only, "only use the decoders and encoders with these comma separated names")
+	fs.StringVar(&o.decoders, "decoders", o.decoders, "only use the decoders with these comma separated names")
+	fs.StringVar(&o.encoders, "encoders", o.encoders, "only use the encoders with these comma separated names")
+	fs.StringVar(&o.as, "as", o.as, "only use the interpreter with this id")
+	fs.BoolVar(&o.onlyMatching, "only-matching", o.onlyMatching, "don't show the decoders that failed")
+	fs.BoolVar(&o.quiet, "quiet", o.quiet, "print nothing, exit with status 1 if nothing matched")
+}
+
+func (o *options) check() {
+	if (o.as != "") && !chade.ValidInterpreterId(o.as) {
+		fmt.Fprintf(os.Stderr, "Unknown interpreter %s (chade --help for a list)\n", o.as)
+		os.Exit(1)
+	}
+}
+
+func (o *options) selection() *chade.Selection {
+	sel := chade.NewSelection(o.only)
+	sel.RestrictDecoders(o.decoders)
+	sel.RestrictEncoders(o.encoders)
+	return sel
+}
+
+type command struct {
+	name string
+	args string
+	help string
+	lookupOptions bool // the options can also be given after the name of the command
+	run func(fs *flag.FlagSet, o *options, args []string)
+}
+
+// the first command is the default one, used when the first argument isn't the name of a command
+var commands []*command = []*command{
+	&command{ "lookup", "<argument>", "interprets argument as a character, code point, escape or bytes and decodes and encodes it", true, runLookup },
+	&command{ "search", "<word>...", "lists the characters whose name contains every word", false, runSearch },
+	&command{ "decode", "<bytes>", "only interprets argument as bytes and runs the decoders on them", true, runDecode },
+	&command{ "encode", "<character>", "only interprets argument as a character or code point and runs the encoders", true, runEncode },
+	&command{ "scan", "[<file>]", "tells which decoders can read the whole file (or standard input)", true, runScan },
+	&command{ "serve", "", "serves a web interface and a JSON API", false, runServe },
+	&command{ "dump", "--charset <charset> <file>", "prints every character of file with its offset, bytes and name", false, runDump },
+	&command{ "convert", "--from <charset> --to <charset> <in> <out>", "converts a file between charsets, - is standard input or output", false, runConvert },
+	&command{ "chart", "<charset>", "prints the table of the characters of a charset", false, runChart },
+	&command{ "diff", "<charset> <charset>", "prints the bytes that two charsets decode differently", false, runDiff },
+	&command{ "roundtrip", "<charset> <string>", "tells which characters of string survive being stored as charset", false, runRoundtrip },
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name { return cmd }
+	}
+	return nil
+}
+
+// usage is the help of chade, the lists of interpreters, decoders and encoders come from the registries
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage: chade [options] [command] <arguments>\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-52s%s\n", cmd.name + " " + cmd.args, cmd.help)
+	}
+	fmt.Fprintf(out, "Without a command the arguments are looked up. Arguments that start with - go after --.\n\nOptions:\n")
+	fs.PrintDefaults()
+
+	fmt.Fprintf(out, "\nInterpreters (--as):\n")
+	for _, interpreter := range chade.TextInterpreters() {
+		fmt.Fprintf(out, "  %-20s%s\n", interpreter.Id(), interpreter.Name())
+	}
+	for _, interpreter := range chade.Interpreters() {
+		fmt.Fprintf(out, "  %-20s%s\n", interpreter.Id(), interpreter.Name())
+	}
+	fmt.Fprintf(out, "\nDecoders (--decoders, --only):\n")
+	for _, decoder := range chade.Decoders() {
+		fmt.Fprintf(out, "  %s\n", decoder.Name())
+	}
+	fmt.Fprintf(out, "\nEncoders (--encoders, --only):\n")
+	for _, encoder := range chade.Encoders() {
+		fmt.Fprintf(out, "  %s\n", encoder.Name())
+	}
+}
+
+// newFlagSet returns the flag set of cmd, with a usage message made from its entry in the commands table
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet("chade " + cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chade %s %s\n\n%s\n", cmd.name, cmd.args, cmd.help)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(fs.Output(), "\nOptions:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parseFlags parses args with fs and returns the positional arguments, unlike fs.Parse it accepts flags after
+// them: "chade chart koi8-r --html". Everything after -- is positional.
+func parseFlags(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for len(args) > 0 {
+		fs.Parse(args)
+		consumed := len(args) - fs.NArg()
+		if (consumed > 0) && (args[consumed-1] == "--") { return append(positional, fs.Args()...) }
+		args = fs.Args()
+		if len(args) == 0 { break }
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return positional
+}
+
+func usageError(fs *flag.FlagSet) {
+	fs.Usage()
+	os.Exit(2)
+}
+
+func runLookup(fs *flag.FlagSet, o *options, args []string) { analyze(fs, o, args, chade.Analyze) }
+func runDecode(fs *flag.FlagSet, o *options, args []string) { analyze(fs, o, args, chade.AnalyzeBytes) }
+func runEncode(fs *flag.FlagSet, o *options, args []string) { analyze(fs, o, args, chade.AnalyzeCodepoints) }
+
+// analyze is the lookup, decode and encode commands, they only differ in the interpretations they keep
+func analyze(fs *flag.FlagSet, o *options, args []string, analyzer func(string, string, *chade.Selection) *chade.Report) {
+	args = parseFlags(fs, args)
+	if len(args) == 0 { usageError(fs) }
+	o.check()
+
+	report := analyzer(strings.TrimSpace(strings.Join(args, " ")), o.as, o.selection())
+	if o.quiet {
+		if !reportMatched(report) { os.Exit(1) }
+		return
+	}
+	if o.onlyMatching { removeRejections(report) }
+
+	switch o.format {
+	case "json":
+		printReportJSON(report)
+	case "text", "":
+		printReport(report)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %s (can be text or json)\n", o.format)
+		os.Exit(1)
+	}
+}
+
+func main() {
+	o := &options{}
+	fs := flag.NewFlagSet("chade", flag.ExitOnError)
+	o.define(fs)
+	batch := fs.Bool("batch", false, "look up every line of a file (or of standard input) and print a table")
+	interactive := fs.Bool("i", false, "read the arguments to look up from standard input, one at a time")
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(os.Args[1:])
+	args := fs.Args()
+
+	cmd := commands[0]
+	if len(args) > 0 {
+		if c := findCommand(args[0]); c != nil {
+			cmd = c
+			args = args[1:]
+		}
+	}
+
+	if (cmd == commands[0]) && (len(args) == 0) && !*batch && !*interactive {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// the options before the command are only read by the commands that look characters up, search has its own
+	// --quiet
+	if !cmd.lookupOptions && !*batch && !*interactive {
+		fs.Visit(func(f *flag.Flag) {
+			if (f.Name == "batch") || (f.Name == "i") || ((cmd.name == "search") && (f.Name == "quiet")) { return }
+			fmt.Fprintf(os.Stderr, "The %s command doesn't take the option --%s\n", cmd.name, f.Name)
+			os.Exit(2)
+		})
+	}
+
+	// the interactive mode prints every answer
+	if *interactive && o.quiet {
+		fmt.Fprintf(os.Stderr, "The option -i doesn't take the option --quiet\n")
+		os.Exit(2)
+	}
+
+	if err := chade.InitUnicodeData(); err != nil {
+		fmt.Fprintf(os.Stderr, "Reading the Unicode data: %v\n", err)
+		os.Exit(1)
+	}
+	if err := chade.InitHTMLEntities(); err != nil {
+		fmt.Fprintf(os.Stderr, "Reading the HTML entities: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case *interactive:
+		o.check()
+		repl(o)
+	case *batch:
+		o.check()
+		runBatch(args, o)
+	default:
+		cmdfs := newFlagSet(cmd)
+		if cmd.lookupOptions { o.define(cmdfs) }
+		cmd.run(cmdfs, o, args)
+	}
+}
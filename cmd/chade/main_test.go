@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+// the output of chade with args must be the same as testdata/<name>.golden, paths in args are relative to the
+// root of the repository
+var goldenCases = []struct {
+	name string
+	args []string
+}{
+	{ "character", []string{ "é" } },
+	{ "bytes", []string{ "C3", "A9" } },
+	{ "unicode-notation", []string{ "U+20AC" } },
+	{ "unicode-data-json", []string{ "--format=json", "--only=utf-8", "U+2460" } },
+	{ "only-json", []string{ "--format=json", "--only=utf-8,iso-8859-1,shift-jis", "C3 A9" } },
+	{ "as", []string{ "--only=utf-8", "--as", "codepoint-hex", "E9" } },
+	{ "mime-word", []string{ "--only=utf-8", "=?ISO-8859-1?Q?Andr=E9?=" } },
+	{ "iso-2022-jp", []string{ "--only=iso-2022-jp,utf-8", "1B 24 42 24 22 1B 28 42" } },
+	{ "dump", []string{ "dump", "--charset", "utf-8", "cmd/chade/testdata/mixed.txt" } },
+	{ "dump-iso-2022-jp", []string{ "dump", "--charset", "iso-2022-jp", "cmd/chade/testdata/iso-2022-jp.txt" } },
+	{ "dump-utf-7", []string{ "dump", "--charset", "utf-7", "cmd/chade/testdata/utf-7.txt" } },
+	{ "convert", []string{ "convert", "--from", "utf-8", "--to", "iso-8859-1", "--on-error", "question", "cmd/chade/testdata/mixed.txt", "-" } },
+	{ "convert-iso-2022-jp", []string{ "convert", "--from", "iso-2022-jp", "--to", "utf-8", "cmd/chade/testdata/iso-2022-jp.txt", "-" } },
+	{ "convert-shift-jis", []string{ "convert", "--from", "utf-8", "--to", "shift_jis", "cmd/chade/testdata/backslash.txt", "-" } },
+	{ "chart", []string{ "chart", "koi8-r" } },
+	{ "diff", []string{ "diff", "iso-8859-1", "windows-1252" } },
+	{ "diff-unix", []string{ "diff", "--codepoints", "cp1047", "CP1047 (EBCDIC z/OS UNIX, 15 is newline)" } },
+	{ "roundtrip", []string{ "roundtrip", "iso-8859-1", "héllo €" } },
+	{ "search", []string{ "search", "euro", "sign" } },
+	{ "decode", []string{ "decode", "--decoders=utf-8,iso-8859-1", "--encoders=utf-8", "C3 A9" } },
+	{ "encode", []string{ "encode", "--encoders=utf-8,utf-16le", "é" } },
+	{ "only-matching", []string{ "--only=utf-8,iso-8859-1", "decode", "C3 A9", "--only-matching" } },
+	{ "batch", []string{ "--batch", "--format=csv", "--only=utf-8,codepoint,iso-8859-1", "cmd/chade/testdata/lines.txt" } },
+	{ "batch-only-matching", []string{ "--batch", "--only=utf-8", "--only-matching", "cmd/chade/testdata/lines.txt" } },
+	{ "scan", []string{ "scan", "--decoders=ascii,utf-8,iso-8859-1", "cmd/chade/testdata/mixed.txt" } },
+	{ "scan-iso-2022-jp", []string{ "scan", "--decoders=ascii,iso-2022-jp,iso-2022-kr", "cmd/chade/testdata/iso-2022-jp.txt" } },
+}
+
+var testdata string
+
+func init() {
+	var err error
+	testdata, err = filepath.Abs("testdata")
+	must(err)
+}
+
+// runMain runs main with args from the root of the repository and returns what it wrote on standard output
+func runMain(t *testing.T, args []string) []byte {
+	wd, err := os.Getwd()
+	if err != nil { t.Fatal(err) }
+	if err := os.Chdir(filepath.Join(testdata, "..", "..", "..")); err != nil { t.Fatal(err) }
+	defer os.Chdir(wd)
+
+	r, w, err := os.Pipe()
+	if err != nil { t.Fatal(err) }
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- b
+	}()
+
+	os.Args = append([]string{ "chade" }, args...)
+	main()
+	w.Close()
+	return <-out
+}
+
+func TestGolden(t *testing.T) {
+	for _, gc := range goldenCases {
+		gc := gc
+		t.Run(gc.name, func(t *testing.T) {
+			got := runMain(t, gc.args)
+			path := filepath.Join(testdata, gc.name + ".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0644); err != nil { t.Fatal(err) }
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil { t.Fatalf("%s, go test -update creates the golden files", err) }
+			if !bytes.Equal(got, want) {
+				t.Errorf("output of chade %v differs from %s:\n%s", gc.args, path, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const replHelp = `Anything that isn't a command is looked up like a command line argument.
+Commands:
+	:decoders		list the decoders (* marks the selected ones)
+	:encoders		list the encoders (* marks the selected ones)
+	:interpreters		list the interpreters and their ids
+	:only name,name...	only use the decoders and encoders with these names
+	:as id			only use the interpreter with this id (:as alone to use all of them)
+	:all			use all decoders and encoders again
+	:json			switch between text and JSON output
+	:history		show previous queries
+	!!			repeat the last query
+	!n			repeat query number n
+	:help			show this message
+	:quit			exit
+`
+
+// repl reads queries from standard input until EOF or :quit, the unicode tables are loaded only once. The
+// options are where the :only, :as and :json commands start from.
+func repl(o *options) {
+	in := bufio.NewReader(os.Stdin)
+	history := []string{}
+	var sel *chade.Selection
+	if (o.only != "") || (o.decoders != "") || (o.encoders != "") { sel = o.selection() }
+	as := o.as
+	jsonOutput := false
+	switch o.format {
+	case "json":
+		jsonOutput = true
+	case "text", "":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %s (can be text or json)\n", o.format)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Type :help for a list of commands\n")
+
+	for {
+		fmt.Printf("chade> ")
+		line, err := in.ReadString('\n')
+		if err != nil {
+			fmt.Printf("\n")
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" { continue }
+
+		if line == "!!" || ((line[0] == '!') && (len(line) > 1) && (line[1] >= '0') && (line[1] <= '9')) {
+			n := len(history)
+			if line != "!!" {
+				n, err = strconv.Atoi(line[1:])
+				if err != nil {
+					fmt.Printf("Bad history reference: %s\n", line)
+					continue
+				}
+			}
+			if (n < 1) || (n > len(history)) {
+				fmt.Printf("No query number %d in history\n", n)
+				continue
+			}
+			line = history[n-1]
+			fmt.Printf("%s\n", line)
+		}
+
+		// a lone ':' is a character to look up
+		if (line[0] == ':') && (len(line) > 1) {
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case ":help":
+				fmt.Print(replHelp)
+			case ":decoders":
+				for _, decoder := range chade.Decoders() {
+					replListItem(decoder.Name(), sel.AcceptsDecoder(decoder.Name()))
+				}
+			case ":encoders":
+				for _, encoder := range chade.Encoders() {
+					replListItem(encoder.Name(), sel.AcceptsEncoder(encoder.Name()))
+				}
+			case ":interpreters":
+				for _, interpreter := range chade.TextInterpreters() {
+					fmt.Printf("  %-20s%s\n", interpreter.Id(), interpreter.Name())
+				}
+				for _, interpreter := range chade.Interpreters() {
+					fmt.Printf("  %-20s%s\n", interpreter.Id(), interpreter.Name())
+				}
+			case ":only":
+				if len(fields) < 2 {
+					fmt.Printf("Usage: :only name,name...\n")
+					continue
+				}
+				sel = chade.NewSelection(strings.Join(fields[1:], ""))
+				fmt.Printf("Using %s\n", sel)
+			case ":as":
+				if len(fields) < 2 {
+					as = ""
+					fmt.Printf("Using all interpreters\n")
+					continue
+				}
+				if !chade.ValidInterpreterId(fields[1]) {
+					fmt.Printf("Unknown interpreter %s (:interpreters for a list)\n", fields[1])
+					continue
+				}
+				as = fields[1]
+				fmt.Printf("Using only %s\n", as)
+			case ":all":
+				sel = nil
+				fmt.Printf("Using %s\n", sel)
+			case ":json":
+				jsonOutput = !jsonOutput
+				if jsonOutput {
+					fmt.Printf("JSON output\n")
+				} else {
+					fmt.Printf("Text output\n")
+				}
+			case ":history":
+				for i, query := range history {
+					fmt.Printf("%4d  %s\n", i+1, query)
+				}
+			case ":quit", ":q":
+				return
+			default:
+				fmt.Printf("Unknown command %s (:help for a list of commands)\n", fields[0])
+			}
+			continue
+		}
+
+		history = append(history, line)
+
+		report := chade.Analyze(line, as, sel)
+		if o.onlyMatching { removeRejections(report) }
+		if jsonOutput {
+			printReportJSON(report)
+		} else {
+			printReport(report)
+		}
+	}
+}
+
+func replListItem(name string, selected bool) {
+	mark := " "
+	if selected { mark = "*" }
+	fmt.Printf("%s %s\n", mark, name)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"os"
+)
+
+func printEncodings(encodings []chade.EncodingResult, indent string) {
+	for _, encoding := range encodings {
+		fmt.Printf("%sEncoded as %s:\t%s\n", indent, encoding.Name, encoding.Value)
+	}
+}
+
+func printReport(r *chade.Report) {
+	fmt.Printf("Argument: [%s]\n", r.Argument)
+
+	if r.Interpreter == "" {
+		fmt.Printf("Could not understand input\n")
+		return
+	}
+
+	for i, it := range r.Interpretations() {
+		if i == 0 {
+			fmt.Printf("Interpreted as %s\n", it.Interpreter)
+		} else {
+			fmt.Printf("\nAlso interpreted as %s\n", it.Interpreter)
+		}
+		printInterpretation(&it)
+	}
+}
+
+func printInterpretation(it *chade.Interpretation) {
+	if it.Text != "" { fmt.Printf("Text: [%s]\n", it.Text) }
+
+	for _, decoding := range it.Decodings {
+		if len(decoding.Decoders) == 0 {
+			fmt.Printf("\n")
+			printEncodings(decoding.Encodings, "")
+		} else {
+			fmt.Printf("Decoded as %v:\n\n", decoding.Decoders)
+			for _, trace := range decoding.Traces {
+				for _, step := range trace.Steps {
+					fmt.Printf("\t%s: %s\n", trace.Decoder, step)
+				}
+				fmt.Printf("\n")
+			}
+			printEncodings(decoding.Encodings, "\t")
+			fmt.Printf("\n")
+		}
+	}
+
+	for _, rejection := range it.Rejections {
+		fmt.Printf("Can not be decoded as %s because %s\n", rejection.Decoder, rejection.Error)
+	}
+}
+
+// reportMatched tells if at least one interpretation of the argument led to a character
+func reportMatched(r *chade.Report) bool {
+	for _, it := range r.Interpretations() {
+		if len(it.Decodings) > 0 { return true }
+	}
+	return false
+}
+
+// removeRejections leaves only the decoders that succeeded in r, for --only-matching
+func removeRejections(r *chade.Report) {
+	r.Rejections = []chade.Rejection{}
+	for i := range r.Alternatives {
+		r.Alternatives[i].Rejections = []chade.Rejection{}
+	}
+}
+
+func printReportJSON(r *chade.Report) {
+	out, err := json.MarshalIndent(r, "", "\t")
+	must(err)
+	os.Stdout.Write(out)
+	fmt.Printf("\n")
+}
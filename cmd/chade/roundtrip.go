@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"os"
+	"strings"
+)
+
+// roundtrip prints, for each character of s, whether it survives being stored as charset
+func roundtrip(charset string, s string) {
+	encoder, ok := chade.FindEncoder(charset)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown charset %s\n", charset)
+		os.Exit(1)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "Roundtrip of [%s] through %s:\n\n", s, encoder.Name())
+
+	counts := make(map[string]int)
+	for _, char := range s {
+		status, bytes, back := chade.RoundtripChar(encoder, char)
+		counts[status]++
+		line := fmt.Sprintf("%-4s  U+%-7s  %-9s", dumpChar(char), fmt.Sprintf("%04X", char), status)
+		switch status {
+		case chade.ROUNDTRIP_ENCODABLE:
+			line += "  " + chade.EncodeBytes(bytes)
+		case chade.ROUNDTRIP_BESTFIT:
+			line += fmt.Sprintf("  %s, read back as [%s]", chade.EncodeBytes(bytes), back)
+		}
+		fmt.Fprintf(out, "%s\n", line)
+	}
+
+	fmt.Fprintf(out, "\n%d encodable, %d best-fit mapped, %d lost\n", counts[chade.ROUNDTRIP_ENCODABLE], counts[chade.ROUNDTRIP_BESTFIT], counts[chade.ROUNDTRIP_LOST])
+
+	if best, length, ok := chade.SmallestCharset(s); ok {
+		fmt.Fprintf(out, "Smallest charset for the whole string: %s (%d bytes)\n", best.Name(), length)
+	} else {
+		fmt.Fprintf(out, "No charset can represent the whole string\n")
+	}
+}
+
+func runRoundtrip(fs *flag.FlagSet, o *options, args []string) { roundtrip(parseRoundtripArgs(fs, args)) }
+
+// parseRoundtripArgs reads the arguments of "chade roundtrip <charset> <string>"
+func parseRoundtripArgs(fs *flag.FlagSet, args []string) (charset string, s string) {
+	args = parseFlags(fs, args)
+	if len(args) < 2 { usageError(fs) }
+	return args[0], strings.Join(args[1:], " ")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+type scanResult struct {
+	Decoder string `json:"decoder"`
+	Characters int `json:"characters"`
+	Invalid int `json:"invalid"` // number of bytes that couldn't be decoded
+	FirstError *chade.DecodeError `json:"first_error,omitempty"`
+	FirstErrorOffset int `json:"first_error_offset"` // offset of the first byte of the sequence that FirstError is about
+}
+
+func runScan(fs *flag.FlagSet, o *options, args []string) {
+	args = parseFlags(fs, args)
+	if len(args) > 1 { usageError(fs) }
+
+	in := os.Stdin
+	if (len(args) == 1) && (args[0] != "-") {
+		file, err := os.Open(args[0])
+		must(err)
+		defer file.Close()
+		in = file
+	}
+	size, results := scan(in, o.selection())
+	matched := false
+	for _, result := range results {
+		if result.FirstError == nil { matched = true }
+	}
+	if o.quiet {
+		if !matched { os.Exit(1) }
+		return
+	}
+	if o.onlyMatching {
+		clean := []scanResult{}
+		for _, result := range results {
+			if result.FirstError == nil { clean = append(clean, result) }
+		}
+		results = clean
+	}
+
+	switch o.format {
+	case "json":
+		out, err := json.MarshalIndent(results, "", "\t")
+		must(err)
+		fmt.Printf("%s\n", out)
+	case "text", "":
+		printScan(size, results)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %s (can be text or json)\n", o.format)
+		os.Exit(1)
+	}
+}
+
+// the input of scan is read scanChunk bytes at a time
+const scanChunk = 64*1024
+
+// scanner decodes the input of scan with one decoder
+type scanner struct {
+	stream chade.StreamDecoder
+	result scanResult
+	offset int // of the first byte of pending
+	pending []byte // the end of the previous chunk, where a character can continue in the next one
+}
+
+// scan decodes the input with every decoder accepted by sel, one character at a time like dump does, and returns
+// its size. The input is read a chunk at a time, every decoder goes through a chunk before the next is read.
+func scan(in io.Reader, sel *chade.Selection) (int, []scanResult) {
+	scanners := []*scanner{}
+	for _, decoder := range chade.Decoders() {
+		if !sel.AcceptsDecoder(decoder.Name()) { continue }
+		scanners = append(scanners, &scanner{ stream: chade.NewStreamDecoder(decoder), result: scanResult{ Decoder: decoder.Name() } })
+	}
+
+	buf := make([]byte, scanChunk)
+	size := 0
+	for eof := false; !eof; {
+		n, err := io.ReadFull(in, buf)
+		if (err == io.EOF) || (err == io.ErrUnexpectedEOF) {
+			eof = true
+		} else {
+			must(err)
+		}
+		size += n
+		for _, s := range scanners { s.scan(buf[:n], eof) }
+	}
+
+	results := []scanResult{}
+	for _, s := range scanners { results = append(results, s.result) }
+	return size, results
+}
+
+func (s *scanner) scan(chunk []byte, eof bool) {
+	buf := append(s.pending, chunk...)
+	pos := 0
+	for pos < len(buf) {
+		if !eof && (len(buf) - pos < chade.MaxStepLen) { break }
+		char, length, _, err := s.stream.Step(buf[pos:])
+		switch {
+		case err != nil:
+			s.fail(s.offset + pos, err)
+			s.result.Invalid += length
+		case char >= 0:
+			s.result.Characters++
+		}
+		pos += length
+	}
+	s.offset += pos
+	s.pending = append([]byte{}, buf[pos:]...)
+
+	if eof {
+		if err := s.stream.End(); err != nil { s.fail(s.offset, err) }
+	}
+}
+
+// fail records err if it's the first error
+func (s *scanner) fail(offset int, err *chade.DecodeError) {
+	if s.result.FirstError != nil { return }
+	s.result.FirstError = err
+	s.result.FirstErrorOffset = offset
+}
+
+func printScan(size int, results []scanResult) {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	// the column of the names is as wide as the longest one, the width of %-*s is in characters
+	width := 0
+	for _, result := range results {
+		if n := utf8.RuneCountInString(result.Decoder); n > width { width = n }
+	}
+
+	fmt.Fprintf(out, "%d bytes\n\n", size)
+	for _, result := range results {
+		if result.FirstError == nil {
+			fmt.Fprintf(out, "%-*s  %d characters\n", width, result.Decoder, result.Characters)
+		} else {
+			fmt.Fprintf(out, "%-*s  %d characters, %d invalid bytes, first at %08X: %s\n", width, result.Decoder, result.Characters, result.Invalid, result.FirstErrorOffset, result.FirstError)
+		}
+	}
+}
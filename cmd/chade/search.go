@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"os"
+	"strings"
+)
+
+func runSearch(fs *flag.FlagSet, o *options, args []string) {
+	fs.BoolVar(&o.quiet, "quiet", o.quiet, "print nothing, exit with status 1 if no character matched")
+	args = parseFlags(fs, args)
+	if len(args) == 0 { usageError(fs) }
+	if !search(args, o.quiet) && o.quiet { os.Exit(1) }
+}
+
+// search prints the characters whose name (or Unicode 1.0 name) contains all the words, ignoring case.
+// Returns true if at least one character matched.
+func search(words []string, quiet bool) bool {
+	for i := range words {
+		words[i] = strings.ToUpper(words[i])
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	found := false
+	for char, ud := range chade.UnicodeDataFile {
+		if ud == nil { continue }
+		if !searchMatch(ud.Name, words) && !searchMatch(ud.Unicode1Name, words) { continue }
+		found = true
+		if quiet { break }
+		fmt.Fprintf(out, "U+%-7s  %-4s  %s\n", fmt.Sprintf("%04X", char), dumpChar(rune(char)), ud.Name)
+	}
+	return found
+}
+
+func searchMatch(name string, words []string) bool {
+	// names of ranges (<CJK Ideograph>, <Hangul Syllable>...) aren't names of the character
+	if (name == "") || strings.HasPrefix(name, "<") { return false }
+	for _, word := range words {
+		if !strings.Contains(name, word) { return false }
+	}
+	return true
+}
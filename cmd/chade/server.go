@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aarzilli/chade"
+	"net/http"
+	"strings"
+)
+
+// serve answers queries over HTTP, the unicode tables must already be loaded and are never modified, so
+// requests can be served concurrently
+func serve(addr string) {
+	http.HandleFunc("/", serveIndex)
+	http.HandleFunc("/api/analyze", serveAnalyze)
+	http.HandleFunc("/api/interpret", serveInterpret)
+	http.HandleFunc("/api/decode", serveDecode)
+	http.HandleFunc("/api/encode", serveEncode)
+
+	fmt.Printf("Listening on %s\n", addr)
+	must(http.ListenAndServe(addr, nil))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(out)
+}
+
+func writeJSONError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	out, _ := json.Marshal(map[string]string{ "error": message })
+	w.Write(out)
+}
+
+// All the API calls accept an optional only=<comma separated names> parameter to restrict the decoders and encoders used
+
+// GET /api/analyze?q=<argument>[&as=<interpreter id>], same as running chade from the command line
+func serveAnalyze(w http.ResponseWriter, req *http.Request) {
+	as := req.FormValue("as")
+	if (as != "") && !chade.ValidInterpreterId(as) {
+		writeJSONError(w, http.StatusBadRequest, "unknown interpreter " + as)
+		return
+	}
+	writeJSON(w, chade.Analyze(strings.TrimSpace(req.FormValue("q")), as, chade.NewSelection(req.FormValue("only"))))
+}
+
+type interpretResult struct {
+	Interpreters []string `json:"interpreters"`
+	Codepoint rune `json:"codepoint"`
+	Bytes []int `json:"bytes"`
+}
+
+// GET /api/interpret?q=<argument>, only runs the interpreters and returns every interpretation
+func serveInterpret(w http.ResponseWriter, req *http.Request) {
+	interpretations := chade.Interpret(strings.TrimSpace(req.FormValue("q")), "")
+	if len(interpretations) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "could not understand input")
+		return
+	}
+	r := []interpretResult{}
+	for _, in := range interpretations {
+		ir := interpretResult{ in.Interpreters, in.Char, nil }
+		if in.Bytes != nil {
+			// a []byte would be marshalled as a base64 string
+			ir.Bytes = make([]int, len(in.Bytes))
+			for i := range in.Bytes { ir.Bytes[i] = int(in.Bytes[i]) }
+		}
+		r = append(r, ir)
+	}
+	writeJSON(w, r)
+}
+
+// GET /api/decode?bytes=<hex bytes>, runs the decoders on bytes written as for the Bytes interpreter
+func serveDecode(w http.ResponseWriter, req *http.Request) {
+	arg := strings.TrimSpace(req.FormValue("bytes"))
+	ok, _, bytes := chade.IntBytes(arg)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "bytes must be hexadecimal numbers separated by spaces")
+		return
+	}
+	r := &chade.Report{ Argument: arg, Interpretation: chade.NewInterpretation("Bytes"), Alternatives: []chade.Interpretation{} }
+	chade.AddDecodings(&r.Interpretation, bytes, chade.NewSelection(req.FormValue("only")))
+	writeJSON(w, r)
+}
+
+// GET /api/encode?codepoint=<hexadecimal code point>, runs the encoders
+func serveEncode(w http.ResponseWriter, req *http.Request) {
+	ok, character, _ := chade.InterpretCodepoint(strings.TrimSpace(req.FormValue("codepoint")), true)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "codepoint must be an hexadecimal number between 0 and 10FFFF")
+		return
+	}
+	writeJSON(w, chade.MakeDecoding([]string{}, character, chade.NewSelection(req.FormValue("only"))))
+}
+
+func serveIndex(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, indexPage)
+}
+
+func runServe(fs *flag.FlagSet, o *options, args []string) { serve(parseServeArgs(fs, args)) }
+
+// parseServeArgs reads the arguments of "chade serve", the only one is --addr
+func parseServeArgs(fs *flag.FlagSet, args []string) string {
+	addr := fs.String("addr", ":8080", "address to listen on")
+	if len(parseFlags(fs, args)) != 0 { usageError(fs) }
+	return *addr
+}
+
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>chade</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+input { font-size: 1.2em; width: 30em; }
+td { padding: 0.1em 1em 0.1em 0; vertical-align: top; font-family: monospace; white-space: pre; }
+.rejected { color: #888; }
+</style>
+</head>
+<body>
+<form id="form">
+<input id="q" autofocus placeholder="a character, a code point, an escape or some bytes">
+<button>Look up</button>
+</form>
+<div id="out"></div>
+<script>
+function esc(s) {
+	return String(s).replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+}
+
+document.getElementById("form").onsubmit = function(ev) {
+	ev.preventDefault();
+	var q = document.getElementById("q").value;
+	fetch("/api/analyze?q=" + encodeURIComponent(q)).then(function(resp) { return resp.json(); }).then(function(r) {
+		var out = "";
+		if (r.interpreter == "") {
+			out = "<p>Could not understand input</p>";
+		} else {
+			[r].concat(r.alternatives).forEach(function(it, i) {
+				out += "<h2>" + (i == 0 ? "Interpreted" : "Also interpreted") + " as " + esc(it.interpreter) + "</h2>";
+				it.decodings.forEach(function(d) {
+					if (d.decoders.length > 0) out += "<h3>Decoded as " + esc(d.decoders.join(", ")) + "</h3>";
+					out += "<table>";
+					d.encodings.forEach(function(e) { out += "<tr><td>" + esc(e.name) + "</td><td>" + esc(e.value) + "</td></tr>"; });
+					out += "</table>";
+				});
+				it.rejections.forEach(function(rej) {
+					var e = rej.error;
+					out += "<p class=\"rejected\">Can not be decoded as " + esc(rej.decoder) + " because " + esc(e.message) +
+						" <small>(" + esc(e.kind) + " at byte " + e.offset + (e.expected ? ", expected " + esc(e.expected) : "") + ")</small></p>";
+				});
+			});
+		}
+		document.getElementById("out").innerHTML = out;
+	});
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,37 @@
+package chade
+
+// On the IBM PC the bytes CP437 reserves for control characters were displayed as these glyphs, text art and
+// old POS terminals use them as such
+var cp437Graphics map[byte]rune = map[byte]rune{
+	0x01: 0x263A, 0x02: 0x263B, 0x03: 0x2665, 0x04: 0x2666, 0x05: 0x2663, 0x06: 0x2660, 0x07: 0x2022,
+	0x08: 0x25D8, 0x09: 0x25CB, 0x0A: 0x25D9, 0x0B: 0x2642, 0x0C: 0x2640, 0x0D: 0x266A, 0x0E: 0x266B, 0x0F: 0x263C,
+	0x10: 0x25BA, 0x11: 0x25C4, 0x12: 0x2195, 0x13: 0x203C, 0x14: 0x00B6, 0x15: 0x00A7, 0x16: 0x25AC, 0x17: 0x21A8,
+	0x18: 0x2191, 0x19: 0x2193, 0x1A: 0x2192, 0x1B: 0x2190, 0x1C: 0x221F, 0x1D: 0x2194, 0x1E: 0x25B2, 0x1F: 0x25BC,
+	0x7F: 0x2302,
+}
+
+var cp437GraphicsReverse map[rune]byte = make(map[rune]byte)
+
+func init() {
+	for b, char := range cp437Graphics {
+		cp437GraphicsReverse[char] = b
+	}
+}
+
+var decCP437 = MakeDecIconv("cp437")
+
+func DecCP437Graphics(in []byte) (rune, *DecodeError) {
+	if len(in) == 1 {
+		if char, ok := cp437Graphics[in[0]]; ok { return char, nil }
+	}
+	return decCP437(in)
+}
+
+func EncCP437Graphics(char rune) (bool, string) {
+	if b, ok := cp437GraphicsReverse[char]; ok { return true, string([]byte{ b }) }
+	ok, out := EncodeCharset("cp437", char)
+	if !ok { return false, "" }
+	// the control characters of these bytes are replaced by the glyphs
+	if _, glyph := cp437Graphics[out[0]]; glyph { return false, "" }
+	return true, out
+}
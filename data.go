@@ -0,0 +1,9 @@
+package chade
+
+import (
+	"embed"
+)
+
+// the data files are built into the package, the Init functions don't depend on the working directory
+//go:embed UnicodeData.txt Blocks.txt entities.txt
+var dataFiles embed.FS
@@ -1,79 +1,150 @@
-package main
+package chade
 
 import (
-	"iconv"
 	"fmt"
+	"github.com/aarzilli/chade/internal/iconv"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
-type Decoder struct {
-	name string
-	fn func([]byte) (bool, int, string)
-}
-
 var decoders []Decoder = []Decoder{
-	Decoder{ "ASCII", DecASCII },
+	&decoderFunc{ "ASCII", "ascii", DecASCII },
 
-	Decoder{ "UTF-8", DecUtf8 },
-	Decoder{ "UTF-16LE", DecUtf16LE },
-	Decoder{ "UTF-16BE", DecUtf16BE },
+	&decoderFunc{ "UTF-8", "utf-8", DecUtf8 },
+	&decoderFunc{ "UTF-16LE", "utf-16le", DecUtf16LE },
+	&decoderFunc{ "UTF-16BE", "utf-16be", DecUtf16BE },
+	&decoderFunc{ "UTF-7", "utf-7", MakeDecStream(utf7.start) },
+	&decoderFunc{ "UTF-7 (IMAP mailbox names)", "utf-7-imap", MakeDecStream(utf7IMAP.start) },
+	
+	&decoderFunc{ "ISO-8859-1 (latin1)", "iso-8859-1", MakeDecIconv("iso-8859-1") },
+	&decoderFunc{ "ISO-8859-2 (latin2, central european)", "iso-8859-2", MakeDecIconv("iso-8859-2") },
+	&decoderFunc{ "ISO-8859-3 (latin3, south european)", "iso-8859-3", MakeDecIconv("iso-8859-3") },
+	&decoderFunc{ "ISO-8859-4 (latin4, north european)", "iso-8859-4", MakeDecIconv("iso-8859-4") },
+	&decoderFunc{ "ISO-8859-5 (cyrillic)", "iso-8859-5", MakeDecIconv("iso-8859-5") },
+	&decoderFunc{ "ISO-8859-6 (arabic)", "iso-8859-6", MakeDecIconv("iso-8859-6") },
+	&decoderFunc{ "ISO-8859-7 (greek)", "iso-8859-7", MakeDecIconv("iso-8859-7") },
+	&decoderFunc{ "ISO-8859-8 (hebrew)", "iso-8859-8", MakeDecIconv("iso-8859-8") },
+	&decoderFunc{ "ISO-8859-9 (latin5, turkish)", "iso-8859-9", MakeDecIconv("iso-8859-9") },
+	&decoderFunc{ "ISO-8859-10 (latin6, nordic)", "iso-8859-10", MakeDecIconv("iso-8859-10") },
+	&decoderFunc{ "ISO-8859-11 (thai)", "iso-8859-11", MakeDecIconv("iso-8859-11") },
+	&decoderFunc{ "ISO-8859-13 (latin7, baltic)", "iso-8859-13", MakeDecIconv("iso-8859-13") },
+	&decoderFunc{ "ISO-8859-14 (latin8, celtic)", "iso-8859-14", MakeDecIconv("iso-8859-14") },
+	&decoderFunc{ "ISO-8859-15 (latin9, latin1 with euro)", "iso-8859-15", MakeDecIconv("iso-8859-15") },
+	&decoderFunc{ "ISO-8859-16 (latin10, south-eastern european)", "iso-8859-16", MakeDecIconv("iso-8859-16") },
 	
-	Decoder{ "ISO-8859-1 (latin1)", MakeDecIconv("iso-8859-1") },
-	Decoder{ "Windows-1251 (latin1 for windows)", MakeDecIconv("windows-1252") },
-	Decoder{ "Windows-1256 (arab windows)", MakeDecIconv("windows-1256") },
-	Decoder{ "ISO-8856-7 (greek)", MakeDecIconv("iso8859-7") },
-	Decoder{ "Windows-1253 (greek windows)", MakeDecIconv("windows-1253") },
-	Decoder{ "ISO-8859-8 (hebrew)", MakeDecIconv("iso-8859-8") },
-	Decoder{ "Windows-1255", MakeDecIconv("windows-1255") },
-	Decoder{ "KOI8-R", MakeDecIconv("koi8-r") },
-	Decoder{ "Windows-1251 (russian windows)", MakeDecIconv("windows-1251") },
-	Decoder{ "Windows-874 (thai windows)", MakeDecIconv("windows-874") },
-	Decoder{ "ISO-8859-11 (thai)", MakeDecIconv("iso-8859-11") },
-	Decoder{ "TIS-620 (thai)", MakeDecIconv("tis-620") },
-	Decoder{ "Windows-1258 (vietnamese)", MakeDecIconv("windows-1258") },
-
-	Decoder{ "BIG5", MakeDecIconv2("big5") },
-
-	Decoder{ "Shift-JIS", ShiftJISDecoder },
+	&decoderFunc{ "Windows-1250 (central european windows)", "windows-1250", MakeDecIconv("windows-1250") },
+	&decoderFunc{ "Windows-1251 (russian windows)", "windows-1251", MakeDecIconv("windows-1251") },
+	&decoderFunc{ "Windows-1252 (latin1 for windows)", "windows-1252", MakeDecIconv("windows-1252") },
+	&decoderFunc{ "Windows-1253 (greek windows)", "windows-1253", MakeDecIconv("windows-1253") },
+	&decoderFunc{ "Windows-1254 (turkish windows)", "windows-1254", MakeDecIconv("windows-1254") },
+	&decoderFunc{ "Windows-1255 (hebrew windows)", "windows-1255", MakeDecIconv("windows-1255") },
+	&decoderFunc{ "Windows-1256 (arab windows)", "windows-1256", MakeDecIconv("windows-1256") },
+	&decoderFunc{ "Windows-1257 (baltic windows)", "windows-1257", MakeDecIconv("windows-1257") },
+	&decoderFunc{ "Windows-1258 (vietnamese)", "windows-1258", MakeDecIconv("windows-1258") },
+	&decoderFunc{ "Windows-874 (thai windows)", "windows-874", MakeDecIconv("windows-874") },
 	
+	&decoderFunc{ "TIS-620 (thai)", "tis-620", MakeDecIconv("tis-620") },
+	&decoderFunc{ "KOI8-R (cyrillic)", "koi8-r", MakeDecIconv("koi8-r") },
+	&decoderFunc{ "KOI8-U (ukrainian)", "koi8-u", MakeDecIconv("koi8-u") },
 	
+	&decoderFunc{ "CP437 (DOS US)", "cp437", MakeDecIconv("cp437") },
+	&decoderFunc{ "CP437 (DOS US with graphic glyphs for control characters)", "", DecCP437Graphics },
+	&decoderFunc{ "CP850 (DOS western european)", "cp850", MakeDecIconv("cp850") },
+	&decoderFunc{ "CP866 (DOS cyrillic)", "cp866", MakeDecIconv("cp866") },
+	&decoderFunc{ "MacRoman (classic Mac OS western)", "macintosh", MakeDecIconv("macintosh") },
+	&decoderFunc{ "MacCyrillic (classic Mac OS cyrillic)", "mac-cyrillic", MakeDecIconv("mac-cyrillic") },
+	
+	&decoderFunc{ "CP037 (EBCDIC US/Canada)", "ibm037", MakeDecIconv("ibm037") },
+	&decoderFunc{ "CP500 (EBCDIC international)", "ibm500", MakeDecIconv("ibm500") },
+	&decoderFunc{ "CP1047 (EBCDIC latin1 open systems)", "ibm1047", MakeDecIconv("ibm1047") },
+	&decoderFunc{ "CP1047 (EBCDIC z/OS UNIX, 15 is newline)", "", DecCP1047Unix },
+	&decoderFunc{ "CP273 (EBCDIC Germany/Austria)", "ibm273", MakeDecIconv("ibm273") },
+
+	&decoderFunc{ "BIG5 (chinese)", "big5", MakeDecIconv2("big5") },
 
-	// EUC-JP
-	// EUC-KR
-	// EUC-CN (chinese)
-	// GBK (chinese)
+	&decoderFunc{ "Shift-JIS", "shift_jis", ShiftJISDecoder },
+
+	&decoderFunc{ "ISO-2022-JP", "iso-2022-jp", MakeDecStream(iso2022JP.start) },
+	&decoderFunc{ "ISO-2022-KR", "iso-2022-kr", MakeDecStream(iso2022KR.start) },
+	&decoderFunc{ "ISO-2022-CN", "iso-2022-cn", MakeDecStream(iso2022CN.start) },
+
+	&decoderFunc{ "EUC-JP", "euc-jp", DecEUCJP },
+	&decoderFunc{ "EUC-KR", "euc-kr", MakeDecIconv2("euc-kr") },
+	&decoderFunc{ "EUC-CN (chinese)", "euc-cn", MakeDecIconv2("euc-cn") },
+	&decoderFunc{ "GBK (chinese)", "gbk", MakeDecIconv2("gbk") },
 }
 
+// Stateful decoders can explain how they got to the character, tracers are indexed by the charset of the decoder
+var decoderTracers map[string]func([]byte) []string = make(map[string]func([]byte) []string)
+
+// Stateful decoders also decode streams, with a StreamDecoder indexed by charset
+var streamDecoders map[string]func() StreamDecoder = make(map[string]func() StreamDecoder)
+
 // byte -> uint8
 
-func DecASCII(in []byte) (bool, int, string) {
-	if len(in) > 1 { return false, -1, "Too many bytes" };
-	if in[0] >= 128 { return false, -1, "MSB set" };
-	return true, int(in[0]), ""
+// the hand written decoders look at in[0] before anything else
+func checkEmpty(in []byte) *DecodeError {
+	if len(in) == 0 { return decodeError(DECODE_TOO_SHORT, 0, "", "No bytes") }
+	return nil
 }
 
-func IconvDecoder(in []byte, charset string) (bool, int, string) {
+func DecASCII(in []byte) (rune, *DecodeError) {
+	if err := checkEmpty(in); err != nil { return -1, err }
+	if len(in) > 1 { return -1, decodeError(DECODE_TOO_LONG, 1, "", "Too many bytes") };
+	if in[0] >= 128 { return -1, decodeError(DECODE_INVALID_BYTE, 0, "00-7F", "MSB set") };
+	return rune(in[0]), nil
+}
+
+func IconvDecoder(in []byte, charset string) (rune, *DecodeError) {
+	if err := checkEmpty(in); err != nil { return -1, err }
 	out, err := iconv.Conv("UTF-8", charset, string(in))
-	if err != nil { return false, -1, "Rejected by iconv" }
-	if len(out) == 0 { return false, -1, "Rejected by iconv" }
-	if len(out) > 1 { return false, -1, "More than one character encoded" }
-	//fmt.Printf("Output %s\n", out)
-	return true, []int(out)[0], ""
+	if err != nil { return -1, decodeError(DECODE_UNMAPPED, 0, "", "Rejected by iconv") }
+	if len(out) == 0 { return -1, decodeError(DECODE_UNMAPPED, 0, "", "Rejected by iconv") }
+	if utf8.RuneCountInString(out) > 1 { return -1, decodeError(DECODE_MULTIPLE, 0, "", "More than one character encoded") }
+	char, _ := utf8.DecodeRuneInString(out)
+	return char, nil
 }
 
-func MakeDecIconv(charset string) func([]byte) (bool, int, string) {
-	return func(in []byte) (bool, int, string) {
-		if len(in) != 1 { return false, -1, "More than one byte in input" }
+func MakeDecIconv(charset string) func([]byte) (rune, *DecodeError) {
+	return func(in []byte) (rune, *DecodeError) {
+		if err := checkEmpty(in); err != nil { return -1, err }
+		if len(in) != 1 { return -1, decodeError(DECODE_TOO_LONG, 1, "", "More than one byte in input") }
 		return IconvDecoder(in, charset)
 	}
 }
 
-func MakeDecIconv2(charset string) func([]byte) (bool, int, string) {
-	return func(in []byte) (bool, int, string) {
-		if len(in) > 2 { return false, -1, "More than two bytes in input" }
-		return IconvDecoder(in, charset)
+// MakeDecIconv2 is for double byte charsets, where all the bytes with the high bit set are lead bytes
+func MakeDecIconv2(charset string) func([]byte) (rune, *DecodeError) {
+	return func(in []byte) (rune, *DecodeError) {
+		if len(in) > 2 { return -1, decodeError(DECODE_TOO_LONG, 2, "", "More than two bytes in input") }
+		char, err := IconvDecoder(in, charset)
+		if (err != nil) && (len(in) == 1) && (in[0] > 0x80) && (in[0] < 0xff) {
+			return -1, decodeError(DECODE_TOO_SHORT, 1, "a trail byte", "Lead byte %02X needs a second byte", in[0])
+		}
+		return char, err
 	}
 }
 
+// EUC-JP has characters of two bytes (JIS X 0208, and half-width katakana after 8E) and of three bytes
+// (JIS X 0212 after 8F), the other bytes below A1 are single byte characters (ASCII and C1 controls)
+func DecEUCJP(in []byte) (rune, *DecodeError) {
+	if err := checkEmpty(in); err != nil { return -1, err }
+	length := 1
+	switch {
+	case in[0] == 0x8e: length = 2
+	case in[0] == 0x8f: length = 3
+	case in[0] == 0xff:
+		return -1, decodeError(DECODE_INVALID_LEAD, 0, "00-FE", "Byte FF can't start an EUC-JP character")
+	case in[0] >= 0xa1: length = 2
+	}
+	if len(in) < length { return -1, decodeError(DECODE_TOO_SHORT, len(in), fmt.Sprintf("%d bytes", length), "Lead byte %02X needs %d bytes", in[0], length) }
+	if len(in) > length { return -1, decodeError(DECODE_TOO_LONG, length, "", "Too many bytes, the first byte indicates %d", length) }
+	for i := 1; i < length; i++ {
+		if (in[i] < 0xa1) || (in[i] > 0xfe) { return -1, decodeError(DECODE_INVALID_TRAIL, i, "A1-FE", "Byte %02X can't be part of an EUC-JP character", in[i]) }
+	}
+	return IconvDecoder(in, "euc-jp")
+}
+
 func Utf8Char1Decode(in byte) (length int, code byte) {
 	if in & 0x80 == 0x00 { return 1, in & 0x7F }
 	if in & 0xE0 == 0xC0 { return 2, in & 0x1F }
@@ -89,34 +160,62 @@ func AcceptUtf8SequenceByte(in byte) (bool, byte) {
 	return (in & 0xC0 == 0x80), in & 0x3F
 }
 
-func DecUtf8(in []byte) (bool, int, string) {
-	var length, acccode int
+// smallest code point that needs a sequence of each length, anything less is overlong
+var utf8Min []rune = []rune{ 0, 0, 0x80, 0x800, 0x10000, 0x200000, 0x4000000 }
+
+func DecUtf8(in []byte) (rune, *DecodeError) {
+	var length int
+	var acccode rune
 	
+	if err := checkEmpty(in); err != nil { return -1, err }
 	length, subcode := Utf8Char1Decode(in[0])
-	acccode = int(subcode)
+	acccode = rune(subcode)
 	if length == -1 {
-		return false, -1, "Invalid first byte of an utf8 sequence (FE or FF)"
-	}
-	if len(in) != length {
-		return false, -1, fmt.Sprintf("First byte requires a sequence of %d characters but %d characters were provided", length, len(in))
+		return -1, decodeError(DECODE_INVALID_LEAD, 0, "0xxxxxxx, 110xxxxx, 1110xxxx or 11110xxx", "Byte %02X can not start an utf8 sequence", in[0])
 	}
 
-	for i, abyte := range in[1:len(in)] {
-		if ok, subcode := AcceptUtf8SequenceByte(abyte); ok {
+	// trail bytes are checked first, a sequence interrupted by a wrong byte isn't just short
+	for i := 1; (i < len(in)) && (i < length); i++ {
+		if ok, subcode := AcceptUtf8SequenceByte(in[i]); ok {
 			acccode <<= 6
-			acccode += int(subcode)
+			acccode += rune(subcode)
 		} else {
-			return false, -1, fmt.Sprintf("Character %d can not be part of an utf8 sequence", i)
+			return -1, decodeError(DECODE_INVALID_TRAIL, i, "10xxxxxx", "Byte %02X can not be part of an utf8 sequence", in[i])
 		}
 	}
 
-	return true, acccode, ""
+	if len(in) < length {
+		return -1, decodeError(DECODE_TOO_SHORT, len(in), fmt.Sprintf("%d bytes", length), "First byte requires a sequence of %d bytes but %d bytes were provided", length, len(in))
+	}
+	if len(in) > length {
+		return -1, decodeError(DECODE_TOO_LONG, length, "", "First byte requires a sequence of %d bytes but %d bytes were provided", length, len(in))
+	}
+
+	if acccode < utf8Min[length] {
+		return -1, decodeError(DECODE_OVERLONG, 0, "", "U+%04X is encoded with %d bytes, it must be encoded with less", acccode, length)
+	}
+	if IsSurrogate(acccode) {
+		return -1, decodeError(DECODE_INVALID_SURROGATE, 0, "", "U+%04X is a surrogate, surrogates can not be encoded in utf8", acccode)
+	}
+	if acccode > 0x10ffff {
+		return -1, decodeError(DECODE_OUT_OF_RANGE, 0, "", "%X is past the last code point (10FFFF)", acccode)
+	}
+
+	return acccode, nil
 }
 
-func DecUtf16LE(in []byte) (bool, int, string) {
-	if (len(in) != 2) && (len(in) != 4) {
-		return false, -1, fmt.Sprintf("Unacceptable number of bytes for an UTF-16 character (can be 2 or 4 was %d)", len(in))
+func checkUtf16Length(in []byte) *DecodeError {
+	switch {
+	case (len(in) == 0) || (len(in) == 1) || (len(in) == 3):
+		return decodeError(DECODE_TOO_SHORT, len(in), "2 or 4 bytes", "Unacceptable number of bytes for an UTF-16 character (can be 2 or 4 was %d)", len(in))
+	case len(in) > 4:
+		return decodeError(DECODE_TOO_LONG, 4, "", "Unacceptable number of bytes for an UTF-16 character (can be 2 or 4 was %d)", len(in))
 	}
+	return nil
+}
+
+func DecUtf16LE(in []byte) (rune, *DecodeError) {
+	if err := checkUtf16Length(in); err != nil { return -1, err }
 
 	ints := make([]uint16, len(in)/2)
 
@@ -127,10 +226,8 @@ func DecUtf16LE(in []byte) (bool, int, string) {
 	return DecUtf16Common(ints)
 }
 
-func DecUtf16BE(in []byte) (bool, int, string) {
-	if (len(in) != 2) && (len(in) != 4) {
-		return false, -1, fmt.Sprintf("Unacceptable number of bytes for an UTF-16 character (can be 2 or 4 was %d)", len(in))
-	}
+func DecUtf16BE(in []byte) (rune, *DecodeError) {
+	if err := checkUtf16Length(in); err != nil { return -1, err }
 
 	ints := make([]uint16, len(in)/2)
 
@@ -141,26 +238,27 @@ func DecUtf16BE(in []byte) (bool, int, string) {
 	return DecUtf16Common(ints)
 }
 
-func DecUtf16Common(ints []uint16) (bool, int, string) {
+func DecUtf16Common(ints []uint16) (rune, *DecodeError) {
+	if (ints[0] >= 0xdc00) && (ints[0] <= 0xdfff) {
+		return -1, decodeError(DECODE_INVALID_SURROGATE, 0, "", "Low surrogate %04X is not preceded by a high surrogate", ints[0])
+	}
+
 	if len(ints) == 1 {
-		return true, int(ints[0]), ""
+		if (ints[0] >= 0xd800) && (ints[0] <= 0xdbff) {
+			return -1, decodeError(DECODE_TOO_SHORT, 2, "a low surrogate (DC00-DFFF)", "High surrogate %04X must be followed by a low surrogate", ints[0])
+		}
+		return rune(ints[0]), nil
 	}
 
 	if (ints[0] < 0xd800) || (ints[0] > 0xdbff) {
-		return false, -1, fmt.Sprintf("First element of the pair is not a high surrogate (%x)", ints[0])
+		return -1, decodeError(DECODE_TOO_LONG, 2, "", "First element of the pair is not a high surrogate (%x)", ints[0])
 	}
 
-	hisur := ints[0] - 0xd800
-
 	if (ints[1] < 0xdc00) || (ints[1] > 0xdfff) {
-		return false, -1, "Second element of the pair is not a low surrogate"
+		return -1, decodeError(DECODE_INVALID_SURROGATE, 2, "DC00-DFFF", "Second element of the pair is not a low surrogate")
 	}
 
-	lowsur := ints[1] - 0xdc00
-
-	result := (uint32(hisur) << 10) + uint32(lowsur) + 0x10000
-
-	return true, int(result), ""
+	return utf16.DecodeRune(rune(ints[0]), rune(ints[1])), nil
 }
 
 const (
@@ -213,40 +311,41 @@ func ClassifyShiftJISByte2(in byte) int {
 
 
 
-func ShiftJISCheckByte2(in []byte) string {
-	if len(in) > 2 { return "Too many bytes (never more than 2 bytes in a Shift-JIS character)" }
-	if len(in) < 2 { return fmt.Sprintf("Not enought bytes for Shift-JIS sequence starting with: %x", in[0]) }
+func ShiftJISCheckByte2(in []byte) *DecodeError {
+	if len(in) > 2 { return decodeError(DECODE_TOO_LONG, 2, "", "Too many bytes (never more than 2 bytes in a Shift-JIS character)") }
+	if len(in) < 2 { return decodeError(DECODE_TOO_SHORT, 1, "a second byte", "Not enought bytes for Shift-JIS sequence starting with: %x", in[0]) }
 	switch ClassifyShiftJISByte2(in[1]) {
 	case FORBIDDEN_SECOND_BYTE:
-		return fmt.Sprintf("Unacceptable second byte: %x", in[1]);
+		return decodeError(DECODE_INVALID_TRAIL, 1, "40-7E or 80-FC", "Unacceptable second byte: %x", in[1]);
 	case SECOND_BYTE_ODD:
 		fallthrough
 	case SECOND_BYTE_EVEN:
-		return ""
+		return nil
 	}
 
-	return ""
+	return nil
 }
 
 
-func ShiftJISDecoder(in []byte) (bool, int, string) {
+func ShiftJISDecoder(in []byte) (rune, *DecodeError) {
+	if err := checkEmpty(in); err != nil { return -1, err }
 	switch ClassifyShiftJISByte1(in[0]) {
 	case SINGLE_BYTE:
-		if len(in) > 1 { return false, -1, "Too many bytes, the first byte indicates only one is needed" }
+		if len(in) > 1 { return -1, decodeError(DECODE_TOO_LONG, 1, "", "Too many bytes, the first byte indicates only one is needed") }
 		return IconvDecoder(in, "shift_jis")
 	case FORBIDDEN_FIRST_BYTE:
-		return false, -1, "First (only?) byte is forbidden in Shift-JIS"
+		return -1, decodeError(DECODE_INVALID_LEAD, 0, "00-7F, 81-9F, A1-DF or E0-EF", "First (only?) byte is forbidden in Shift-JIS")
 	case FIRST_BYTE:
-		if r := ShiftJISCheckByte2(in); r != "" {
-			return false, -1, r
+		if err := ShiftJISCheckByte2(in); err != nil {
+			return -1, err
 		}
 		return IconvDecoder(in, "shift_jis")
 	case NONSTANDARD_FIRST_BYTE:
-		if r := ShiftJISCheckByte2(in); r != "" {
-			return false, -1, r
+		if err := ShiftJISCheckByte2(in); err != nil {
+			return -1, err
 		}
-		return false, -1, "Non-standard first byte used (but everything else is ok, so this could be an emoji)"
+		return -1, decodeError(DECODE_NONSTANDARD, 0, "", "Non-standard first byte used (but everything else is ok, so this could be an emoji)")
 	}
 
-	return false, -1, fmt.Sprintf("This shouldn't happen %d", ClassifyShiftJISByte1(in[0]))
-}
\ No newline at end of file
+	return -1, decodeError(DECODE_INVALID_LEAD, 0, "", "This shouldn't happen %d", ClassifyShiftJISByte1(in[0]))
+}
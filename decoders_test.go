@@ -0,0 +1,327 @@
+package chade
+
+import (
+	"fmt"
+	"github.com/aarzilli/chade/internal/iconv"
+	"testing"
+)
+
+// a decodeCase expects in to decode to char, or to be rejected with an error of the given kind
+type decodeCase struct {
+	in string
+	char rune
+	kind string
+}
+
+var decodeCases = []struct {
+	decoder string
+	cases []decodeCase
+}{
+	{ "ASCII", []decodeCase{
+		{ "A", 'A', "" },
+		{ "\x80", -1, DECODE_INVALID_BYTE },
+		{ "AB", -1, DECODE_TOO_LONG },
+		{ "", -1, DECODE_TOO_SHORT },
+	} },
+	{ "UTF-8", []decodeCase{
+		{ "A", 'A', "" },
+		{ "\xc3\xa9", 0xe9, "" },
+		{ "\xe2\x82\xac", 0x20ac, "" },
+		{ "\xf0\x9f\x98\x80", 0x1f600, "" },
+		{ "\xc3", -1, DECODE_TOO_SHORT },
+		{ "\xc3\x41", -1, DECODE_INVALID_TRAIL },
+		{ "\xe2\x41", -1, DECODE_INVALID_TRAIL },
+		{ "\x80", -1, DECODE_INVALID_LEAD },
+		{ "\xff", -1, DECODE_INVALID_LEAD },
+		{ "\xc3\xa9\x41", -1, DECODE_TOO_LONG },
+		{ "\xc0\x80", -1, DECODE_OVERLONG },
+		{ "\xe0\x80\xaf", -1, DECODE_OVERLONG },
+		{ "\xed\xa0\x80", -1, DECODE_INVALID_SURROGATE },
+		{ "\xf4\x90\x80\x80", -1, DECODE_OUT_OF_RANGE },
+		{ "", -1, DECODE_TOO_SHORT },
+	} },
+	{ "UTF-16LE", []decodeCase{
+		{ "\xe9\x00", 0xe9, "" },
+		{ "\x3d\xd8\x00\xde", 0x1f600, "" },
+		{ "\xe9", -1, DECODE_TOO_SHORT },
+		{ "\x3d\xd8", -1, DECODE_TOO_SHORT },
+		{ "\x00\xde", -1, DECODE_INVALID_SURROGATE },
+		{ "\x3d\xd8\x41\x00", -1, DECODE_INVALID_SURROGATE },
+		{ "\xe9\x00\xe9\x00", -1, DECODE_TOO_LONG },
+		{ "", -1, DECODE_TOO_SHORT },
+	} },
+	{ "UTF-16BE", []decodeCase{
+		{ "\x00\xe9", 0xe9, "" },
+		{ "\xd8\x3d\xde\x00", 0x1f600, "" },
+		{ "\xd8\x3d", -1, DECODE_TOO_SHORT },
+	} },
+	{ "UTF-7", []decodeCase{
+		{ "+AOk-", 0xe9, "" },
+		{ "+AOk", 0xe9, "" },
+		{ "+-", '+', "" },
+		{ "+2D3eAA-", 0x1f600, "" },
+		{ "\xe9", -1, DECODE_INVALID_BYTE },
+		{ "+A-", -1, DECODE_INVALID_ESCAPE },
+		{ "AB", -1, DECODE_MULTIPLE },
+	} },
+	{ "UTF-7 (IMAP mailbox names)", []decodeCase{
+		{ "&AOk-", 0xe9, "" },
+		{ "&-", '&', "" },
+		{ "&AOk", -1, DECODE_INVALID_ESCAPE },
+		{ "&AEE-", -1, DECODE_INVALID_ESCAPE },
+	} },
+	{ "ISO-8859-1 (latin1)", []decodeCase{
+		{ "\xe9", 0xe9, "" },
+		{ "\xe9\xe9", -1, DECODE_TOO_LONG },
+		{ "", -1, DECODE_TOO_SHORT },
+	} },
+	{ "ISO-8859-15 (latin9, latin1 with euro)", []decodeCase{
+		{ "\xa4", 0x20ac, "" },
+	} },
+	{ "Windows-1252 (latin1 for windows)", []decodeCase{
+		{ "\x80", 0x20ac, "" },
+		{ "\x81", -1, DECODE_UNMAPPED },
+	} },
+	{ "KOI8-R (cyrillic)", []decodeCase{
+		{ "\xc1", 0x430, "" },
+	} },
+	{ "CP437 (DOS US)", []decodeCase{
+		{ "\x82", 0xe9, "" },
+	} },
+	{ "CP437 (DOS US with graphic glyphs for control characters)", []decodeCase{
+		{ "\x01", 0x263a, "" },
+		{ "\x82", 0xe9, "" },
+	} },
+	{ "MacRoman (classic Mac OS western)", []decodeCase{
+		{ "\x8e", 0xe9, "" },
+	} },
+	{ "CP037 (EBCDIC US/Canada)", []decodeCase{
+		{ "\xc1", 'A', "" },
+		{ "\x25", 0x0a, "" },
+		{ "\x15", 0x85, "" },
+	} },
+	{ "CP1047 (EBCDIC z/OS UNIX, 15 is newline)", []decodeCase{
+		{ "\xc1", 'A', "" },
+		{ "\x15", 0x0a, "" },
+		{ "\x25", 0x85, "" },
+	} },
+	{ "BIG5 (chinese)", []decodeCase{
+		{ "\xa4\xa4", 0x4e2d, "" },
+		{ "\xa4", -1, DECODE_TOO_SHORT },
+	} },
+	{ "Shift-JIS", []decodeCase{
+		{ "A", 'A', "" },
+		{ "\xb1", 0xff71, "" },
+		{ "\x82\xa0", 0x3042, "" },
+		{ "\x82", -1, DECODE_TOO_SHORT },
+		{ "\x82\x20", -1, DECODE_INVALID_TRAIL },
+		{ "\xa0", -1, DECODE_INVALID_LEAD },
+		{ "\xf5\x40", -1, DECODE_NONSTANDARD },
+		{ "A\x41", -1, DECODE_TOO_LONG },
+		{ "", -1, DECODE_TOO_SHORT },
+	} },
+	{ "EUC-JP", []decodeCase{
+		{ "A", 'A', "" },
+		{ "\xa4\xa2", 0x3042, "" },
+		{ "\x8e\xb1", 0xff71, "" },
+		{ "\x8f\xab\xa1", 0xe1, "" },
+		{ "\xa4", -1, DECODE_TOO_SHORT },
+		{ "\x8f\xab", -1, DECODE_TOO_SHORT },
+		{ "\xa4\x41", -1, DECODE_INVALID_TRAIL },
+		{ "\xff", -1, DECODE_INVALID_LEAD },
+		{ "A\x41", -1, DECODE_TOO_LONG },
+	} },
+	{ "EUC-KR", []decodeCase{
+		{ "\xb0\xa1", 0xac00, "" },
+		{ "\xb0", -1, DECODE_TOO_SHORT },
+	} },
+	{ "EUC-CN (chinese)", []decodeCase{
+		{ "\xd6\xd0", 0x4e2d, "" },
+	} },
+	{ "GBK (chinese)", []decodeCase{
+		{ "\xd6\xd0", 0x4e2d, "" },
+		{ "\x81\x40", 0x4e02, "" },
+	} },
+	{ "ISO-2022-JP", []decodeCase{
+		{ "\x1b$B$\"\x1b(B", 0x3042, "" },
+		{ "\x1b(J\\", 0xa5, "" },
+		{ "\x1b$B", -1, DECODE_NO_CHARACTER },
+		{ "\x1b$B$", -1, DECODE_TOO_SHORT },
+		{ "\x1b%Z", -1, DECODE_INVALID_ESCAPE },
+		{ "\xe9", -1, DECODE_INVALID_BYTE },
+	} },
+	{ "ISO-2022-KR", []decodeCase{
+		{ "\x1b$)C\x0e\x30\x21\x0f", 0xac00, "" },
+		{ "\x0e", -1, DECODE_INVALID_ESCAPE },
+	} },
+	{ "ISO-2022-CN", []decodeCase{
+		{ "\x1b$)A\x0e\x56\x50\x0f", 0x4e2d, "" },
+	} },
+}
+
+func decoderByName(name string) Decoder {
+	for _, decoder := range decoders {
+		if decoder.Name() == name { return decoder }
+	}
+	return nil
+}
+
+func TestDecoders(t *testing.T) {
+	for _, dc := range decodeCases {
+		dc := dc
+		t.Run(dc.decoder, func(t *testing.T) {
+			decoder := decoderByName(dc.decoder)
+			if decoder == nil { t.Fatalf("no decoder named %s", dc.decoder) }
+			for _, c := range dc.cases {
+				char, err := decoder.Decode([]byte(c.in))
+				switch {
+				case (c.kind == "") && (err != nil):
+					t.Errorf("% X: unexpected error %s", c.in, err)
+				case (c.kind == "") && (char != c.char):
+					t.Errorf("% X: decoded as U+%04X, expected U+%04X", c.in, char, c.char)
+				case (c.kind != "") && (err == nil):
+					t.Errorf("% X: decoded as U+%04X, expected a %s error", c.in, char, c.kind)
+				case (c.kind != "") && (err.Kind != c.kind):
+					t.Errorf("% X: got error %s, expected a %s error", c.in, err, c.kind)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeStep(t *testing.T) {
+	utf8 := decoderByName("UTF-8")
+
+	char, length, err := DecodeStep(utf8, []byte("\xc3\xa9AB"))
+	if (err != nil) || (char != 0xe9) || (length != 2) {
+		t.Errorf("got U+%04X length %d error %v, expected U+00E9 length 2", char, length, err)
+	}
+
+	// the invalid trail byte is more interesting than the missing bytes of the shorter lengths
+	if _, _, err := DecodeStep(utf8, []byte("\xe2\x41\x41\x41")); (err == nil) || (err.Kind != DECODE_INVALID_TRAIL) || (err.Offset != 1) {
+		t.Errorf("got error %v, expected an invalid trail byte at offset 1", err)
+	}
+
+	if _, _, err := DecodeStep(utf8, []byte("\xe2\x82")); (err == nil) || (err.Kind != DECODE_TOO_SHORT) {
+		t.Errorf("got error %v, expected a too short error", err)
+	}
+}
+
+// findCases are the names given to FindDecoder and FindEncoder and the name of what they must find, "" when
+// they must find nothing
+var findCases = []struct {
+	charset string
+	decoder string
+	encoder string
+}{
+	{ "shift_jis", "Shift-JIS", "Shift-JIS" },
+	{ "ISO-8859-1", "ISO-8859-1 (latin1)", "ISO-8859-1 (latin1)" },
+	{ "iso-8859-1 (latin1)", "ISO-8859-1 (latin1)", "ISO-8859-1 (latin1)" },
+	{ "CP1047 (EBCDIC z/OS UNIX, 15 is newline)", "CP1047 (EBCDIC z/OS UNIX, 15 is newline)", "CP1047 (EBCDIC z/OS UNIX, 15 is newline)" },
+	{ "", "", "" },
+	{ " ", "", "" },
+	{ ",", "", "" },
+	{ "utf-8,shift_jis", "", "" },
+	{ "codepoint", "", "" },
+}
+
+func TestFind(t *testing.T) {
+	for _, fc := range findCases {
+		decoder, ok := FindDecoder(fc.charset)
+		if (ok != (fc.decoder != "")) || (ok && (decoder.Name() != fc.decoder)) {
+			t.Errorf("FindDecoder(%q) found %v %v, expected %q", fc.charset, ok, decoder, fc.decoder)
+		}
+		encoder, ok := FindEncoder(fc.charset)
+		if (ok != (fc.encoder != "")) || (ok && (encoder.Name() != fc.encoder)) {
+			t.Errorf("FindEncoder(%q) found %v %v, expected %q", fc.charset, ok, encoder, fc.encoder)
+		}
+	}
+}
+
+// streamCases are decoded by a StreamDecoder that is never given more than MaxStepLen bytes
+var streamCases = []struct {
+	decoder string
+	in string
+	chars string
+}{
+	{ "ISO-2022-JP", "A \x1b$B%F%9%H\x1b(B\n", "A テスト\n" },
+	{ "ISO-2022-JP", "\x1b$(D+!\x1b(I1\x1b(B", "\u00e1\uff71" },
+	{ "ISO-2022-KR", "\x1b$)C\x0e\x30\x21\x0fA", "\uac00A" },
+	{ "UTF-7", "Hi +AOk-", "Hi é" },
+	{ "UTF-7", "+AOkA6QDp-+-+2D3eAA-.", "ééé+\U0001f600." },
+	{ "UTF-7", "+AOk.", "é." },
+	{ "UTF-7 (IMAP mailbox names)", "&AOkA6Q-&-x", "éé&x" },
+	{ "UTF-8", "\xc3\xa9\xe2\x82\xac", "é€" },
+}
+
+func TestStreamDecoder(t *testing.T) {
+	for _, sc := range streamCases {
+		stream := NewStreamDecoder(decoderByName(sc.decoder))
+		chars := []rune{}
+		for offset := 0; offset < len(sc.in); {
+			end := offset + MaxStepLen
+			if end > len(sc.in) { end = len(sc.in) }
+			char, length, _, err := stream.Step([]byte(sc.in[offset:end]))
+			if err != nil { t.Fatalf("%s % X: error at byte %d: %s", sc.decoder, sc.in, offset, err) }
+			if char >= 0 { chars = append(chars, char) }
+			offset += length
+		}
+		if err := stream.End(); err != nil { t.Errorf("%s % X: error at the end: %s", sc.decoder, sc.in, err) }
+		if string(chars) != sc.chars { t.Errorf("%s % X: decoded as %q, expected %q", sc.decoder, sc.in, string(chars), sc.chars) }
+	}
+}
+
+// codepointLimit returns the last code point examined by the exhaustive tests, in short mode only the BMP
+func codepointLimit() rune {
+	if testing.Short() { return 0xffff }
+	return 0x10ffff
+}
+
+func TestShiftJISExhaustive(t *testing.T) {
+	count := 0
+	for i := rune(0); i <= codepointLimit(); i++ {
+		// workaround for bug in glibc iconv implementation of shift_jis encoder
+		if (i == 0x5c) || (i == 0x7e) { continue }
+		// other workaround for adaptivity in glibc iconv implementation
+		if (i >= 0xffe0) && (i <= 0xffe2) { continue }
+
+		shiftJISStr, err := iconv.Conv("shift_jis", "UTF-8", string(i))
+		if (err != nil) || (len(shiftJISStr) == 0) { continue }
+		count++
+		out, derr := ShiftJISDecoder([]byte(shiftJISStr))
+		if derr != nil {
+			t.Fatalf("error decoding encoded shift jis character at codepoint %X: %s", i, derr)
+		}
+		if out != i {
+			t.Fatalf("decoding mismatch for character at codepoint %X, returned %X", i, out)
+		}
+	}
+	t.Logf("examined %d characters", count)
+}
+
+func TestUnicodeDecodersExhaustive(t *testing.T) {
+	for _, charset := range []string{ "UTF-8", "UTF-16LE", "UTF-16BE" } {
+		charset := charset
+		t.Run(charset, func(t *testing.T) {
+			decoder := decoderByName(charset)
+			for i := rune(0); i <= codepointLimit(); i++ {
+				if IsSurrogate(i) { continue }
+				encoded, err := iconv.Conv(charset, "UTF-8", string(i))
+				if err != nil { t.Fatalf("iconv error at %X: %s", i, err) }
+				redec, derr := decoder.Decode([]byte(encoded))
+				if derr != nil {
+					t.Fatalf("decoding of %s encoded %X failed: %s", charset, i, derr)
+				}
+				if redec != i {
+					t.Fatalf("decoding of %s encoded %X erroneous, returned %X", charset, i, redec)
+				}
+			}
+		})
+	}
+}
+
+func ExampleDecodeError() {
+	_, err := decoderByName("UTF-8").Decode([]byte("\xc3\x41"))
+	fmt.Println(err.Kind, err.Offset, err.Expected)
+	// Output: invalid-trail 1 10xxxxxx
+}
@@ -0,0 +1,25 @@
+package chade
+
+// The IBM tables (and iconv) map 0x15 to U+0085 NEXT LINE and 0x25 to U+000A LINE FEED in every EBCDIC code
+// page. On z/OS UNIX System Services CP1047 text uses 0x15 as the newline character instead, so that is what
+// a '\n' becomes when a file is converted there: these functions swap the two for CP1047.
+
+var decCP1047 = MakeDecIconv("ibm1047")
+
+func DecCP1047Unix(in []byte) (rune, *DecodeError) {
+	if len(in) == 1 {
+		switch in[0] {
+		case 0x15: return 0x0a, nil
+		case 0x25: return 0x85, nil
+		}
+	}
+	return decCP1047(in)
+}
+
+func EncCP1047Unix(char rune) (bool, string) {
+	switch char {
+	case 0x0a: return true, "\x15"
+	case 0x85: return true, "\x25"
+	}
+	return EncodeCharset("ibm1047", char)
+}
@@ -1,55 +1,100 @@
-package main
+package chade
 
 import (
-	"iconv"
 	"fmt"
+	"github.com/aarzilli/chade/internal/iconv"
 	"strings"
 	"unicode"
+	"unicode/utf16"
 )
 
-type Encoder struct {
-	name string
-	fn func(int) (bool, string)
-}
-
 var encoders []Encoder = []Encoder{
-	Encoder{ "Character", EncCharacter },
+	&encoderFunc{ "Character", "", EncCharacter },
 	
-	Encoder{ "Codepoint", EncCodepoint },
-	Encoder{ "Unicode Informations", EncUnicodeInfo },
+	&encoderFunc{ "Codepoint", "", EncCodepoint },
+	&encoderFunc{ "Unicode Informations", "", EncUnicodeInfo },
 
-	Encoder{ "Java String Literal", EncJava },
-	Encoder{ "HTML Entity", EncHTML },
+	&encoderFunc{ "Java String Literal", "", EncJava },
+	&encoderFunc{ "JavaScript/JSON String Literal", "", EncJavaScript },
+	&encoderFunc{ "Go String Literal", "", EncGo },
+	&encoderFunc{ "Python String Literal", "", EncPython },
+	&encoderFunc{ "C/C++ String Literal", "", EncC },
+	&encoderFunc{ "C/C++ UTF-8 String Literal", "", EncCBytes },
+	&encoderFunc{ "Rust String Literal", "", EncRust },
+	&encoderFunc{ "CSS Escape", "", EncCSS },
+	&encoderFunc{ "SQL Unicode Literal", "", EncSQL },
+	&encoderFunc{ "HTML Entity", "", EncHTML },
+	&encoderFunc{ "MIME encoded-word (Q encoding)", "", EncMIMEQ },
+	&encoderFunc{ "MIME encoded-word (B encoding)", "", EncMIMEB },
+	
+	&encoderFunc{ "ASCII", "ascii", EncASCII },
+	
+	&encoderFunc{ "UTF-8", "utf-8", EncUtf8 },
+	&encoderFunc{ "UTF-16LE", "UTF-16LE", MakeEncIconv("UTF-16LE", false) },
+	&encoderFunc{ "UTF-16BE", "UTF-16BE", MakeEncIconv("UTF-16BE", false) },
+	&encoderFunc{ "UTF-7", "utf-7", MakeEncUtf7(utf7) },
+	&encoderFunc{ "UTF-7 (IMAP mailbox names)", "utf-7-imap", MakeEncUtf7(utf7IMAP) },
+	
+	&encoderFunc{ "ISO-8859-1 (latin1)", "iso-8859-1", MakeEncIconv("iso-8859-1", true) },
+	&encoderFunc{ "ISO-8859-2 (latin2, central european)", "iso-8859-2", MakeEncIconv("iso-8859-2", true) },
+	&encoderFunc{ "ISO-8859-3 (latin3, south european)", "iso-8859-3", MakeEncIconv("iso-8859-3", true) },
+	&encoderFunc{ "ISO-8859-4 (latin4, north european)", "iso-8859-4", MakeEncIconv("iso-8859-4", true) },
+	&encoderFunc{ "ISO-8859-5 (cyrillic)", "iso-8859-5", MakeEncIconv("iso-8859-5", true) },
+	&encoderFunc{ "ISO-8859-6 (arabic)", "iso-8859-6", MakeEncIconv("iso-8859-6", true) },
+	&encoderFunc{ "ISO-8859-7 (greek)", "iso-8859-7", MakeEncIconv("iso-8859-7", true) },
+	&encoderFunc{ "ISO-8859-8 (hebrew)", "iso-8859-8", MakeEncIconv("iso-8859-8", true) },
+	&encoderFunc{ "ISO-8859-9 (latin5, turkish)", "iso-8859-9", MakeEncIconv("iso-8859-9", true) },
+	&encoderFunc{ "ISO-8859-10 (latin6, nordic)", "iso-8859-10", MakeEncIconv("iso-8859-10", true) },
+	&encoderFunc{ "ISO-8859-11 (thai)", "iso-8859-11", MakeEncIconv("iso-8859-11", true) },
+	&encoderFunc{ "ISO-8859-13 (latin7, baltic)", "iso-8859-13", MakeEncIconv("iso-8859-13", true) },
+	&encoderFunc{ "ISO-8859-14 (latin8, celtic)", "iso-8859-14", MakeEncIconv("iso-8859-14", true) },
+	&encoderFunc{ "ISO-8859-15 (latin9, latin1 with euro)", "iso-8859-15", MakeEncIconv("iso-8859-15", true) },
+	&encoderFunc{ "ISO-8859-16 (latin10, south-eastern european)", "iso-8859-16", MakeEncIconv("iso-8859-16", true) },
+	
+	&encoderFunc{ "Windows-1250 (central european windows)", "windows-1250", MakeEncIconv("windows-1250", true) },
+	&encoderFunc{ "Windows-1251 (russian windows)", "windows-1251", MakeEncIconv("windows-1251", true) },
+	&encoderFunc{ "Windows-1252 (latin1 for windows)", "windows-1252", MakeEncIconv("windows-1252", true) },
+	&encoderFunc{ "Windows-1253 (greek windows)", "windows-1253", MakeEncIconv("windows-1253", true) },
+	&encoderFunc{ "Windows-1254 (turkish windows)", "windows-1254", MakeEncIconv("windows-1254", true) },
+	&encoderFunc{ "Windows-1255 (hebrew windows)", "windows-1255", MakeEncIconv("windows-1255", true) },
+	&encoderFunc{ "Windows-1256 (arab windows)", "windows-1256", MakeEncIconv("windows-1256", true) },
+	&encoderFunc{ "Windows-1257 (baltic windows)", "windows-1257", MakeEncIconv("windows-1257", true) },
+	&encoderFunc{ "Windows-1258 (vietnamese)", "windows-1258", MakeEncIconv("windows-1258", true) },
+	&encoderFunc{ "Windows-874 (thai windows)", "windows-874", MakeEncIconv("windows-874", true) },
 	
-	Encoder{ "ASCII", EncASCII },
+	&encoderFunc{ "TIS-620 (thai)", "tis-620", MakeEncIconv("tis-620", true) },
+	&encoderFunc{ "KOI8-R (cyrillic)", "koi8-r", MakeEncIconv("koi8-r", true) },
+	&encoderFunc{ "KOI8-U (ukrainian)", "koi8-u", MakeEncIconv("koi8-u", true) },
 	
-	Encoder{ "UTF-8", EncUtf8 },
-	Encoder{ "UTF-16LE", MakeEncIconv("UTF-16LE", false) },
-	Encoder{ "UTF-16BE", MakeEncIconv("UTF-16BE", false) },
+	&encoderFunc{ "CP437 (DOS US)", "cp437", MakeEncIconv("cp437", true) },
+	&byteEncoderFunc{ "CP437 (DOS US with graphic glyphs for control characters)", EncCP437Graphics, true },
+	&encoderFunc{ "CP850 (DOS western european)", "cp850", MakeEncIconv("cp850", true) },
+	&encoderFunc{ "CP866 (DOS cyrillic)", "cp866", MakeEncIconv("cp866", true) },
+	&encoderFunc{ "MacRoman (classic Mac OS western)", "macintosh", MakeEncIconv("macintosh", true) },
+	&encoderFunc{ "MacCyrillic (classic Mac OS cyrillic)", "mac-cyrillic", MakeEncIconv("mac-cyrillic", true) },
 	
-	Encoder{ "ISO-8859-1 (latin1)", MakeEncIconv("iso-8859-1", true) },
-	Encoder{ "Windows-1252 (latin1 for windows)", MakeEncIconv("windows-1252", true) },
+	// EBCDIC isn't compatible with ASCII, ASCII characters must be encoded too
+	&encoderFunc{ "CP037 (EBCDIC US/Canada)", "ibm037", MakeEncIconv("ibm037", false) },
+	&encoderFunc{ "CP500 (EBCDIC international)", "ibm500", MakeEncIconv("ibm500", false) },
+	&encoderFunc{ "CP1047 (EBCDIC latin1 open systems)", "ibm1047", MakeEncIconv("ibm1047", false) },
+	&byteEncoderFunc{ "CP1047 (EBCDIC z/OS UNIX, 15 is newline)", EncCP1047Unix, false },
+	&encoderFunc{ "CP273 (EBCDIC Germany/Austria)", "ibm273", MakeEncIconv("ibm273", false) },
 	
-	Encoder{ "Windows-1256 (arab windows)", MakeEncIconv("windows-1256", true) },
-	Encoder{ "ISO-8859-7 (greek)", MakeEncIconv("iso-8859-7", true) },
-	Encoder{ "Windows-1253 (greek windows)", MakeEncIconv("windows-1253", true) },
-	Encoder{ "ISO-8859-8 (hebrew)", MakeEncIconv("iso-8859-8", true) },
-	Encoder{ "Windows-1255 (hebrew windows)", MakeEncIconv("windows-1255", true) },
-	Encoder{ "Shift-JIS", MakeEncIconv("shift_jis", true) },
-	Encoder{ "EUC-JP", MakeEncIconv("euc-jp", true) },
-	Encoder{ "EUC-KR", MakeEncIconv("euc-kr", true) },
-	Encoder{ "KOI8-R (cyrillic)", MakeEncIconv("koi8-r", true) },
-	Encoder{ "Windows-1251 (russian windows)", MakeEncIconv("windows-1251", true) },
-	Encoder{ "Windows-874 (thai windows)", MakeEncIconv("windows-874", true) },
-	Encoder{ "ISO-8859-11 (thai)", MakeEncIconv("iso-8859-11", true) },
-	Encoder{ "TIS-620 (thai)", MakeEncIconv("tis-620", true) },
-	Encoder{ "Windows-1258 (vietnamese)", MakeEncIconv("windows-1258", true) },
-	Encoder{ "EUC-CN (chinese)", MakeEncIconv("euc-cn", true) },
-	Encoder{ "BIG5 (chinese)", MakeEncIconv("big5", true) },
-	Encoder{ "GBK (chinese)", MakeEncIconv("gbk", true) },
-}
-
-func encodeBytes(s string) string {
+	// leaving out ASCII also hides \ and ~, that glibc writes as 5C and 7E but reads back as U+00A5 and U+203E
+	&encoderFunc{ "Shift-JIS", "shift_jis", MakeEncIconv("shift_jis", true) },
+	&encoderFunc{ "EUC-JP", "euc-jp", MakeEncIconv("euc-jp", true) },
+	&encoderFunc{ "EUC-KR", "euc-kr", MakeEncIconv("euc-kr", true) },
+	&encoderFunc{ "EUC-CN (chinese)", "euc-cn", MakeEncIconv("euc-cn", true) },
+	&encoderFunc{ "BIG5 (chinese)", "big5", MakeEncIconv("big5", true) },
+	&encoderFunc{ "GBK (chinese)", "gbk", MakeEncIconv("gbk", true) },
+	
+	&encoderFunc{ "ISO-2022-JP", "iso-2022-jp", MakeEncISO2022("iso-2022-jp") },
+	&encoderFunc{ "ISO-2022-KR", "iso-2022-kr", MakeEncISO2022("iso-2022-kr") },
+	&encoderFunc{ "ISO-2022-CN", "iso-2022-cn", MakeEncISO2022("iso-2022-cn") },
+}
+
+// EncodeBytes writes the bytes of s in hexadecimal, "(hex) C3 A9"
+func EncodeBytes(s string) string {
 	r := make([]string, 0)
 	r = append(r, "(hex)")
 	for i := 0; i < len(s); i++ {
@@ -58,7 +103,7 @@ func encodeBytes(s string) string {
 	return strings.Join(r, " ")
 }
 
-func EncCharacter(char int) (bool, string) {
+func EncCharacter(char rune) (bool, string) {
 
 	if unicode.Is(unicode.Cc, char) || unicode.Is(unicode.Cf, char) || unicode.Is(unicode.Co, char) || unicode.Is(unicode.Cs, char) || unicode.Is(unicode.Zl, char) || unicode.Is(unicode.Zp, char) || unicode.Is(unicode.Zs, char) { return false, "" }
 	s := string(char)
@@ -66,51 +111,145 @@ func EncCharacter(char int) (bool, string) {
 	return true, s
 }
 
-func EncCodepoint(char int) (bool, string) {
+func EncCodepoint(char rune) (bool, string) {
 	return true, fmt.Sprintf("%X (decimal: %d)", char, char)
 }
 
-func EncUnicodeInfo(char int) (bool, string) {
+func EncUnicodeInfo(char rune) (bool, string) {
+	if (char < 0) || (int(char) >= len(UnicodeDataFile)) || (UnicodeDataFile[char] == nil) { return false, "" }
 	return true, "\n"+UnicodeDataFile[char].String()
 }
 
-func EncUtf8(char int) (bool, string) {
+func EncUtf8(char rune) (bool, string) {
 	s := string(char)
-	return true, encodeBytes(s)
+	return true, EncodeBytes(s)
 }
 
-func EncASCII(char int) (bool, string) {
+func EncASCII(char rune) (bool, string) {
 	if char < 128 {
-		return true, encodeBytes(string(char))
+		return true, EncodeBytes(string(char))
 	}
 	return false, ""
 }
 
-func EncJava(char int) (bool, string) {
-	return true, fmt.Sprintf("\\u%04X", char)
+func IsSurrogate(char rune) bool {
+	return utf16.IsSurrogate(char)
 }
 
-func MakeEncIconv(charset string, excludeAscii bool) func(char int) (bool, string) {
-	return func(char int) (bool, string) {
-		s := string(char)
+// returns the \uXXXX escape of char, or the escapes of its surrogate pair for characters outside the BMP
+func escapeUtf16(char rune) string {
+	if char < 0x10000 { return fmt.Sprintf("\\u%04X", char) }
+	hi, lo := utf16.EncodeRune(char)
+	return fmt.Sprintf("\\u%04X\\u%04X", hi, lo)
+}
+
+// returns \uXXXX for characters in the BMP and \UXXXXXXXX for everything else
+func EscapeUniversal(char rune) string {
+	if char < 0x10000 { return fmt.Sprintf("\\u%04X", char) }
+	return fmt.Sprintf("\\U%08X", char)
+}
+
+func EncJava(char rune) (bool, string) {
+	// unicode escapes are translated before the source is parsed, line terminators, quotes and backslashes can not be written with them
+	switch char {
+	case '\n': return true, "\"\\n\""
+	case '\r': return true, "\"\\r\""
+	case '"': return true, "\"\\\"\""
+	case '\\': return true, "\"\\\\\""
+	}
+	return true, "\"" + escapeUtf16(char) + "\""
+}
+
+func EncJavaScript(char rune) (bool, string) {
+	return true, "\"" + escapeUtf16(char) + "\""
+}
+
+func EncGo(char rune) (bool, string) {
+	if IsSurrogate(char) { return false, "" }
+	return true, "\"" + EscapeUniversal(char) + "\""
+}
+
+func EncPython(char rune) (bool, string) {
+	return true, "\"" + EscapeUniversal(char) + "\""
+}
+
+func EncC(char rune) (bool, string) {
+	// universal character names can not designate surrogates or characters in the basic character set
+	if IsSurrogate(char) { return false, "" }
+	// a \x escape is a byte of the narrow string, not a code point: for the C1 controls, which have no universal
+	// character name either, there's only the UTF-8 literal
+	if (char >= 0x80) && (char < 0xa0) { return false, "" }
+	if (char < 0x80) && (char != '$') && (char != '@') && (char != '`') {
+		return true, fmt.Sprintf("\"\\x%02X\"", char)
+	}
+	return true, "\"" + EscapeUniversal(char) + "\""
+}
+
+func EncCBytes(char rune) (bool, string) {
+	if IsSurrogate(char) { return false, "" }
+	s := string(char)
+	r := "\""
+	for i := 0; i < len(s); i++ {
+		r += fmt.Sprintf("\\x%02X", s[i])
+	}
+	return true, r + "\""
+}
+
+func EncRust(char rune) (bool, string) {
+	if IsSurrogate(char) { return false, "" }
+	return true, fmt.Sprintf("\"\\u{%X}\"", char)
+}
+
+func EncCSS(char rune) (bool, string) {
+	// the six digits form does not need a terminating space
+	return true, fmt.Sprintf("\\%06X", char)
+}
+
+func EncSQL(char rune) (bool, string) {
+	if char < 0x10000 { return true, fmt.Sprintf("U&'\\%04X'", char) }
+	return true, fmt.Sprintf("U&'\\+%06X'", char)
+}
+
+// EncodeCharset returns the bytes that encode char in charset
+func EncodeCharset(charset string, char rune) (bool, string) {
+	out, err := iconv.Conv(charset, "UTF-8", string(char))
+	if err != nil { return false, "" }
+	if len(out) == 0 { return false, "" }
+	return true, out
+}
+
+// EncodeRaw returns the bytes that encode char with encoder, ok is false if char can't be encoded or if the
+// encoder doesn't produce bytes
+func EncodeRaw(encoder Encoder, char rune) (bool, string) {
+	if be, ok := encoder.(ByteEncoder); ok { return be.EncodeRaw(char) }
+	if encoder.Charset() == "" { return false, "" }
+	return EncodeCharset(encoder.Charset(), char)
+}
+
+// ProducesBytes tells if encoder writes characters in a charset rather than in a notation
+func ProducesBytes(encoder Encoder) bool {
+	_, ok := encoder.(ByteEncoder)
+	return ok || (encoder.Charset() != "")
+}
+
+func MakeEncIconv(charset string, excludeAscii bool) func(char rune) (bool, string) {
+	return func(char rune) (bool, string) {
 		if excludeAscii && (char < 128) { return false, "" }
-		// workaround for bug in glibc iconv implementation of shift_jis encoder
-		if charset == "shift_jis" {
-			if char == 0x5c { return false, "" }
-			if char == 0x7e { return false, "" }
-		}
-		out, err := iconv.Conv(charset, "UTF-8", s)
-		if err != nil { return false, "" }
-		if len(out) == 0 { return false, "" }
-		return true, encodeBytes(out)
+		ok, out := EncodeCharset(charset, char)
+		if !ok { return false, "" }
+		return true, EncodeBytes(out)
 	}
 }
 
-func EncHTML(char int) (bool, string) {
+func EncHTML(char rune) (bool, string) {
 	symb, ok := entities[char]
 	symbStr := ""
 	if ok {
 		symbStr = fmt.Sprintf(" entity: &%s;", symb)
 	}
-	return true, fmt.Sprintf("decimal: &#%d; hexadecimal: &#%X;%s", char, char, symbStr)
-}
\ No newline at end of file
+	return true, fmt.Sprintf("decimal: %s hexadecimal: &#x%X;%s", HTMLDecimalReference(char), char, symbStr)
+}
+
+func HTMLDecimalReference(char rune) string {
+	return fmt.Sprintf("&#%d;", char)
+}
@@ -1,30 +1,30 @@
-package main
+package chade
 
 import (
-	"os"
 	"bufio"
 	"fmt"
 	"strings"
 )
 
-var entities map[int]string = make(map[int]string)
-var entityLookup map[string]int = make(map[string]int)
+var entities map[rune]string = make(map[rune]string)
+var entityLookup map[string]rune = make(map[string]rune)
 
-func InitHTMLEntities() {
-	file, err := os.Open("entities.txt", os.O_RDONLY, 0)
-	must(err)
+func InitHTMLEntities() error {
+	file, err := dataFiles.Open("entities.txt")
+	if err != nil { return err }
 	defer file.Close()
 	in := bufio.NewReader(file)
 
 	for line, err := in.ReadString('\n'); err == nil; line, err = in.ReadString('\n') {
 		line = strings.TrimSpace(line)
-		split := strings.Split(line, "\t", 2)
+		split := strings.SplitN(line, "\t", 2)
+		if (len(split) != 2) || !strings.HasPrefix(split[0], "0x") { return fmt.Errorf("entities.txt: malformed line %q", line) }
 		name := split[1]
-		var codepoint int
-		_, err := fmt.Sscanf(split[0][2:], "%x", &codepoint)
-		must(err)
+		var codepoint rune
+		if _, err := fmt.Sscanf(split[0][2:], "%x", &codepoint); err != nil { return fmt.Errorf("entities.txt: %q: %v", line, err) }
 		entities[codepoint] = name
 		entityLookup[name] = codepoint
 	}
+	return nil
 }
 
@@ -0,0 +1,39 @@
+package chade
+
+import (
+	"fmt"
+)
+
+// Kinds of DecodeError
+const (
+	DECODE_TOO_SHORT = "too-short" // the character needs more bytes
+	DECODE_TOO_LONG = "too-long" // the character ends before the input does
+	DECODE_INVALID_LEAD = "invalid-lead" // the byte can't start a character
+	DECODE_INVALID_TRAIL = "invalid-trail" // the byte can't continue the character started by the lead byte
+	DECODE_INVALID_BYTE = "invalid-byte" // the byte can't appear at all in the encoding
+	DECODE_INVALID_SURROGATE = "invalid-surrogate" // unpaired UTF-16 surrogate, or a surrogate encoded in UTF-8
+	DECODE_OVERLONG = "overlong" // the character has a shorter encoding, which is the only valid one
+	DECODE_OUT_OF_RANGE = "out-of-range" // past U+10FFFF
+	DECODE_INVALID_ESCAPE = "invalid-escape" // malformed escape sequence, shift or base64 run of a stateful encoding
+	DECODE_UNMAPPED = "unmapped" // well formed, but no character is assigned to the bytes
+	DECODE_NONSTANDARD = "nonstandard" // the bytes are in a vendor specific area
+	DECODE_NO_CHARACTER = "no-character" // the input only changes the state of the decoder
+	DECODE_MULTIPLE = "multiple-characters" // the input encodes more than one character
+)
+
+// DecodeError explains why a decoder rejected its input
+type DecodeError struct {
+	Kind string `json:"kind"`
+	Offset int `json:"offset"` // of the byte where the problem was found
+	Expected string `json:"expected"` // what would have been acceptable at Offset, empty if there's nothing useful to say
+	Message string `json:"message"`
+}
+
+func decodeError(kind string, offset int, expected string, format string, args ...interface{}) *DecodeError {
+	return &DecodeError{ kind, offset, expected, fmt.Sprintf(format, args...) }
+}
+
+func (e *DecodeError) Error() string {
+	if e.Expected == "" { return fmt.Sprintf("%s (%s at byte %d)", e.Message, e.Kind, e.Offset) }
+	return fmt.Sprintf("%s (%s at byte %d, expected %s)", e.Message, e.Kind, e.Offset, e.Expected)
+}
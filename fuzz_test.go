@@ -0,0 +1,207 @@
+package chade
+
+import (
+	"bufio"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// seedCharacters returns the first and last character of every block of Blocks.txt, the seed corpus of the fuzz
+// targets is made of their encodings and notations
+func seedCharacters(f *testing.F) []rune {
+	file, err := os.Open("Blocks.txt")
+	if err != nil { f.Fatal(err) }
+	defer file.Close()
+	in := bufio.NewReader(file)
+
+	r := []rune{}
+	for line, err := in.ReadString('\n'); err == nil; line, err = in.ReadString('\n') {
+		if strings.HasPrefix(line, "#") { continue }
+		var start, end rune
+		if _, err := fmt.Sscanf(line, "%x..%x;", &start, &end); err != nil { continue }
+		r = append(r, start, end)
+	}
+	if len(r) == 0 { f.Fatal("no blocks in Blocks.txt") }
+	return r
+}
+
+// duplicateMappings are the sequences that decode to the same character as another one, which is the one
+// the encoder writes
+var duplicateMappings = map[string]map[string]string{
+	"big5": {
+		"\xa2\xcc": "\xa4\x51", // U+5341
+		"\xa2\xce": "\xa4\xca", // U+5345
+		"\xf9\xe9": "\xa2\xa5", // U+255E
+		"\xf9\xea": "\xa2\xa6", // U+256A
+		"\xf9\xeb": "\xa2\xa7", // U+2561
+		"\xf9\xf9": "\xa2\xa4", // U+2550
+		"\xf9\xfa": "\xa2\x7e", // U+256D
+		"\xf9\xfb": "\xa2\xa1", // U+256E
+		"\xf9\xfc": "\xa2\xa2", // U+2570
+		"\xf9\xfd": "\xa2\xa3", // U+256F
+	},
+}
+
+func FuzzDecoders(f *testing.F) {
+	for _, char := range seedCharacters(f) {
+		for _, encoder := range encoders {
+			if ok, out := EncodeRaw(encoder, char); ok { f.Add([]byte(out)) }
+		}
+	}
+	f.Add([]byte{})
+	f.Add([]byte("\xc0\x80"))
+	f.Add([]byte("\xed\xa0\x80"))
+	f.Add([]byte("\xf4\x90\x80\x80"))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		for _, decoder := range decoders {
+			char, err := decoder.Decode(in)
+			if err != nil {
+				if (err.Kind == "") || (err.Message == "") || (err.Offset < 0) || (err.Offset > len(in)) {
+					t.Errorf("%s: malformed error for % X: %#v", decoder.Name(), in, err)
+				}
+				continue
+			}
+			if decoder.Charset() == "" { continue }
+
+			ok, out := EncodeCharset(decoder.Charset(), char)
+			if !ok {
+				t.Errorf("%s decodes % X as U+%04X, which can not be encoded back", decoder.Name(), in, char)
+				continue
+			}
+			if out == string(in) { continue }
+			// in the stateful charsets a character has more than one encoding (where to shift, which escape
+			// sequence), the bytes written by the encoder only have to decode to the same character
+			if Stateful(decoder) {
+				if back, err := decoder.Decode([]byte(out)); (err != nil) || (back != char) {
+					t.Errorf("%s decodes % X as U+%04X, but not its own encoding % X", decoder.Name(), in, char, out)
+				}
+				continue
+			}
+			if duplicateMappings[decoder.Charset()][string(in)] != out {
+				t.Errorf("%s decodes % X as U+%04X, which is encoded back as % X", decoder.Name(), in, char, out)
+			}
+		}
+	})
+}
+
+// notations of code points and the interpreter that must read them back
+var codepointNotations = []struct {
+	interpreter string
+	write func(rune) string
+}{
+	{ "java", escapeUtf16 },
+	{ "universal-name", func(char rune) string { return fmt.Sprintf("\\U%08X", char) } },
+	{ "brace-escape", func(char rune) string { return fmt.Sprintf("\\u{%X}", char) } },
+	{ "perl", func(char rune) string { return fmt.Sprintf("\\x{%X}", char) } },
+	{ "unicode-notation", func(char rune) string { return fmt.Sprintf("U+%04X", char) } },
+	{ "codepoint-0x", func(char rune) string { return fmt.Sprintf("0x%X", char) } },
+	{ "css", func(char rune) string { return fmt.Sprintf("\\%X", char) } },
+	{ "html-dec", HTMLDecimalReference },
+	{ "html-hex", func(char rune) string { return fmt.Sprintf("&#x%X;", char) } },
+	{ "codepoint-dec", func(char rune) string { return fmt.Sprintf("%d", char) } },
+	{ "codepoint-hex", func(char rune) string { return fmt.Sprintf("%X", char) } },
+}
+
+// notations of bytes and the interpreter that must read them back, min is the least number of bytes the
+// notation can be used for
+var byteNotations = []struct {
+	interpreter string
+	min int
+	write func([]byte) string
+}{
+	{ "c-octal", 1, func(b []byte) string { return writeBytes(b, "\\%o", "") } },
+	{ "python-bytes", 1, func(b []byte) string { return "b'" + writeBytes(b, "\\x%02x", "") + "'" } },
+	{ "quoted-printable", 1, func(b []byte) string { return writeBytes(b, "=%02X", "") } },
+	{ "bytes", 1, func(b []byte) string { return writeBytes(b, "%02X", " ") } },
+	{ "hex-string", 1, func(b []byte) string { return writeBytes(b, "%02x", "") } },
+	{ "bytes-0x", 2, func(b []byte) string { return writeBytes(b, "0x%02X", ", ") } },
+	{ "bytes-x", 1, func(b []byte) string { return writeBytes(b, "\\x%02x", "") } },
+	{ "decimal-array", 2, func(b []byte) string { return "[" + writeBytes(b, "%d", ", ") + "]" } },
+	{ "base32", 1, func(b []byte) string { return base32.StdEncoding.EncodeToString(b) } },
+	{ "base64", 1, func(b []byte) string { return base64.StdEncoding.EncodeToString(b) } },
+}
+
+func writeBytes(b []byte, format string, sep string) string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = fmt.Sprintf(format, b[i])
+	}
+	return strings.Join(r, sep)
+}
+
+func interpreterById(id string) Interpreter {
+	for _, interpreter := range interpreters {
+		if interpreter.Id() == id { return interpreter }
+	}
+	return nil
+}
+
+func FuzzInterpreters(f *testing.F) {
+	if err := InitHTMLEntities(); err != nil { f.Fatal(err) }
+	for _, char := range seedCharacters(f) {
+		for _, notation := range codepointNotations {
+			f.Add(notation.write(char))
+		}
+		for _, notation := range byteNotations {
+			f.Add(notation.write([]byte(string(char))))
+		}
+		f.Add("=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(string(char))) + "?=")
+	}
+	f.Add("")
+	f.Add("&eacute;")
+	f.Add("\\uD83D\\uDE00")
+
+	f.Fuzz(func(t *testing.T, arg string) {
+		for _, interpreter := range interpreters {
+			ok, char, bytes := interpreter.Interpret(arg)
+			if !ok { continue }
+			if (bytes == nil) && ((char < 0) || (char > 0x10ffff)) {
+				t.Errorf("%s interprets %q as the invalid code point %X", interpreter.Id(), arg, char)
+			}
+			if (bytes != nil) && (len(bytes) == 0) {
+				t.Errorf("%s interprets %q as no bytes", interpreter.Id(), arg)
+			}
+		}
+		for _, interpreter := range textInterpreters {
+			ok, chars, decoderNames := interpreter.InterpretText(arg)
+			if !ok { continue }
+			if (len(chars) == 0) || (len(chars) != len(decoderNames)) {
+				t.Errorf("%s interprets %q as %d characters with %d decoders", interpreter.Id(), arg, len(chars), len(decoderNames))
+			}
+		}
+	})
+}
+
+// every notation written by chade must be read back by its interpreter
+func FuzzNotations(f *testing.F) {
+	for _, char := range seedCharacters(f) {
+		f.Add(char)
+	}
+
+	f.Fuzz(func(t *testing.T, char rune) {
+		if (char < 0) || (char > 0x10ffff) || IsSurrogate(char) { return }
+
+		for _, notation := range codepointNotations {
+			arg := notation.write(char)
+			ok, got, bytes := interpreterById(notation.interpreter).Interpret(arg)
+			if !ok || (bytes != nil) || (got != char) {
+				t.Errorf("%s does not read %q back as U+%04X (%v, %X, % X)", notation.interpreter, arg, char, ok, got, bytes)
+			}
+		}
+
+		encoded := []byte(string(char))
+		for _, notation := range byteNotations {
+			if len(encoded) < notation.min { continue }
+			arg := notation.write(encoded)
+			ok, _, bytes := interpreterById(notation.interpreter).Interpret(arg)
+			if !ok || (string(bytes) != string(encoded)) {
+				t.Errorf("%s does not read %q back as % X (%v, % X)", notation.interpreter, arg, encoded, ok, bytes)
+			}
+		}
+	})
+}
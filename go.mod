@@ -0,0 +1,3 @@
+module github.com/aarzilli/chade
+
+go 1.21
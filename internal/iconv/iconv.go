@@ -0,0 +1,125 @@
+// Package iconv converts strings between character sets with the iconv of the C library
+package iconv
+
+/*
+#include <errno.h>
+#include <iconv.h>
+#include <stdlib.h>
+
+// chade_iconv hides the char** arguments of iconv, go keeps track of the positions with inleft and outleft
+static size_t chade_iconv(iconv_t cd, char *in, size_t *inleft, char *out, size_t *outleft, int *err) {
+	char *inp = in;
+	char *outp = out;
+	size_t r = iconv(cd, in == NULL ? NULL : &inp, inleft, &outp, outleft);
+	*err = (r == (size_t)-1) ? errno : 0;
+	return r;
+}
+
+static int chade_iconv_failed(iconv_t cd) {
+	return cd == (iconv_t)-1;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+var (
+	ErrInvalid = errors.New("iconv: invalid sequence")
+	ErrIncomplete = errors.New("iconv: incomplete sequence")
+)
+
+const bufSize = 64*1024
+
+// A Converter is an open conversion, it keeps the state of stateful charsets from a call of Convert to the next
+// so that a stream can be converted a piece at a time
+type Converter struct {
+	cd C.iconv_t
+	buf *C.char
+}
+
+// Open starts a conversion from the charset from to the charset to, it fails if iconv doesn't know either
+func Open(to, from string) (*Converter, error) {
+	cto, cfrom := C.CString(to), C.CString(from)
+	defer C.free(unsafe.Pointer(cto))
+	defer C.free(unsafe.Pointer(cfrom))
+
+	cd, err := C.iconv_open(cto, cfrom)
+	if C.chade_iconv_failed(cd) != 0 { return nil, fmt.Errorf("iconv: conversion from %s to %s: %v", from, to, err) }
+	return &Converter{ cd, (*C.char)(C.malloc(bufSize)) }, nil
+}
+
+func (c *Converter) Close() {
+	C.iconv_close(c.cd)
+	C.free(unsafe.Pointer(c.buf))
+}
+
+// Convert converts as much of in as it can, n is the number of bytes of in that were used. It stops with
+// ErrInvalid at the first sequence that can't be converted and with ErrIncomplete if in ends in the middle
+// of a sequence, in[n:] is where the sequence starts.
+func (c *Converter) Convert(in []byte) (out []byte, n int, err error) {
+	out = []byte{}
+	for {
+		inleft := C.size_t(len(in) - n)
+		if inleft == 0 { return out, n, nil }
+		outleft := C.size_t(bufSize)
+		var errno C.int
+		// in has no pointers, iconv can read it where it is
+		C.chade_iconv(c.cd, (*C.char)(unsafe.Pointer(&in[n])), &inleft, c.buf, &outleft, &errno)
+		n = len(in) - int(inleft)
+		out = append(out, C.GoBytes(unsafe.Pointer(c.buf), C.int(bufSize - outleft))...)
+		if err := convertError(errno); err != errBufferFull { return out, n, err }
+	}
+}
+
+// Reset brings the conversion back to its initial state and returns what that takes in the output, the
+// closing escape sequence of a stateful charset
+func (c *Converter) Reset() []byte {
+	out := []byte{}
+	for {
+		outleft := C.size_t(bufSize)
+		var errno C.int
+		C.chade_iconv(c.cd, nil, nil, c.buf, &outleft, &errno)
+		out = append(out, C.GoBytes(unsafe.Pointer(c.buf), C.int(bufSize - outleft))...)
+		if convertError(errno) != errBufferFull { return out }
+	}
+}
+
+// the output buffer is full, the conversion continues with an empty one
+var errBufferFull = errors.New("iconv: output buffer full")
+
+func convertError(errno C.int) error {
+	switch errno {
+	case 0:
+		return nil
+	case C.E2BIG:
+		return errBufferFull
+	case C.EILSEQ:
+		return ErrInvalid
+	case C.EINVAL:
+		return ErrIncomplete
+	}
+	return fmt.Errorf("iconv: error %d", int(errno))
+}
+
+// Conv converts s from the charset from to the charset to, any invalid or incomplete sequence in s is an error
+func Conv(to, from, s string) (string, error) {
+	c, err := Open(to, from)
+	if err != nil { return "", err }
+	defer c.Close()
+
+	out, n, err := c.Convert([]byte(s))
+	switch err {
+	case nil:
+		// resets the state of stateful encodings, which may write a closing escape sequence
+		return string(append(out, c.Reset()...)), nil
+	case ErrInvalid:
+		return string(out), fmt.Errorf("iconv: invalid %s sequence at byte %d", from, n)
+	case ErrIncomplete:
+		return string(out), fmt.Errorf("iconv: incomplete %s sequence at byte %d", from, n)
+	}
+	return string(out), err
+}
@@ -1,72 +1,233 @@
-package main
+package chade
 
 import (
-	"regexp"
+	"encoding/base32"
+	"encoding/base64"
 	"fmt"
-	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf16"
 )
 
-type Interpreter struct {
-	name string
-	fn func(string) (bool, int, []byte);
-}
-
 var interpreters []Interpreter = []Interpreter{
-	Interpreter{ "Character", IntCharacter },
-	Interpreter{ "Java literal", IntJava },
-	Interpreter{ "HTML decimal character reference", IntHTMLDec },
-	Interpreter{ "HTML hexadecimal character reference", IntHTMLHex },
-	Interpreter{ "HTML entity", IntHTMLEntity },
-	Interpreter{ "Bytes", IntBytes },
+	&interpreterFunc{ "Character", "character", IntCharacter },
+	&interpreterFunc{ "Java literal", "java", IntJava },
+	&interpreterFunc{ "Python/C/Go universal character name", "universal-name", IntUniversalName },
+	&interpreterFunc{ "JavaScript/Rust code point escape", "brace-escape", IntBraceEscape },
+	&interpreterFunc{ "Perl code point escape", "perl", IntPerl },
+	&interpreterFunc{ "Unicode notation", "unicode-notation", IntUnicodeNotation },
+	&interpreterFunc{ "0x prefixed code point", "codepoint-0x", IntHexCodepoint },
+	&interpreterFunc{ "C octal escapes", "c-octal", IntCOctal },
+	&interpreterFunc{ "CSS escape", "css", IntCSS },
+	&interpreterFunc{ "Python byte literal", "python-bytes", IntPythonBytes },
+	&interpreterFunc{ "HTML decimal character reference", "html-dec", IntHTMLDec },
+	&interpreterFunc{ "HTML hexadecimal character reference", "html-hex", IntHTMLHex },
+	&interpreterFunc{ "HTML entity", "html-entity", IntHTMLEntity },
+	&interpreterFunc{ "Quoted-printable", "quoted-printable", IntQuotedPrintable },
+	&interpreterFunc{ "Bytes", "bytes", IntBytes },
+	&interpreterFunc{ "Hexadecimal string", "hex-string", IntHexString },
+	&interpreterFunc{ "0x prefixed bytes", "bytes-0x", IntHexPrefixedBytes },
+	&interpreterFunc{ "\\x escaped bytes", "bytes-x", IntHexEscapes },
+	&interpreterFunc{ "Decimal byte array", "decimal-array", IntDecimalArray },
+	&interpreterFunc{ "Base32", "base32", IntBase32 },
+	&interpreterFunc{ "Base64", "base64", IntBase64 },
+	&interpreterFunc{ "Decimal code point", "codepoint-dec", IntCodepointDec },
+	&interpreterFunc{ "Hexadecimal code point", "codepoint-hex", IntCodepointHex },
 }
 
-func IntCharacter(arg string) (bool, int, []byte) {
-	varg := []int(arg)
+func IntCharacter(arg string) (bool, rune, []byte) {
+	varg := []rune(arg)
 	if len(varg) == 1 {
 		return true, varg[0], nil
 	}
 	return false, -1, nil
 }
 
-func interpretCodepoint(arg string, hex bool) (bool, int, []byte) {
-	var num int
-	var err os.Error
-	if hex {
-		_, err = fmt.Sscanf(arg, "%x", &num)
-	} else {
-		_, err = fmt.Sscanf(arg, "%d", &num)
-	}
+// InterpretCodepoint reads arg as a code point, all of arg must be a number
+func InterpretCodepoint(arg string, hex bool) (bool, rune, []byte) {
+	base := 10
+	if hex { base = 16 }
+	num, err := strconv.ParseUint(arg, base, 32)
 	if err != nil { return false, -1, nil }
-	if num < 0 { return false, -1 , nil }
-	if num > 0x10ffff { return false, -1, nil }
-	return true, num, nil
+	if num > unicode.MaxRune { return false, -1, nil }
+	return true, rune(num), nil
+}
+
+var decimalRE *regexp.Regexp = regexp.MustCompile("^[0-9]+$")
+
+// 233, a bare number is most likely bytes but it could be a code point
+func IntCodepointDec(arg string) (bool, rune, []byte) {
+	if !decimalRE.MatchString(arg) { return false, -1, nil }
+	return InterpretCodepoint(arg, false)
+}
+
+var hexadecimalRE *regexp.Regexp = regexp.MustCompile("^[0-9a-fA-F]+$")
+
+// E9
+func IntCodepointHex(arg string) (bool, rune, []byte) {
+	if !hexadecimalRE.MatchString(arg) { return false, -1, nil }
+	return InterpretCodepoint(arg, true)
 }
 
-var javaRE *regexp.Regexp = regexp.MustCompile("^\\\\[uU][0-9a-fA-F]+$")
+var javaRE *regexp.Regexp = regexp.MustCompile("^\\\\u[0-9a-fA-F]+(\\\\u[0-9a-fA-F]+)?$")
 
-func IntJava(arg string) (bool, int, []byte) {
+// Java (and JavaScript, Python) \uXXXX literals, characters outside the BMP are written as a surrogate pair
+func IntJava(arg string) (bool, rune, []byte) {
 	if !javaRE.MatchString(arg) { return false, -1, nil }
-	return interpretCodepoint(arg[2:], true)
+	switch len(arg) {
+	case 6:
+		return InterpretCodepoint(arg[2:], true)
+	case 12:
+		var hi, lo rune
+		if _, err := fmt.Sscanf(arg[2:6], "%x", &hi); err != nil { return false, -1, nil }
+		if _, err := fmt.Sscanf(arg[8:12], "%x", &lo); err != nil { return false, -1, nil }
+		if (hi < 0xd800) || (hi > 0xdbff) { return false, -1, nil }
+		if (lo < 0xdc00) || (lo > 0xdfff) { return false, -1, nil }
+		return true, utf16.DecodeRune(hi, lo), nil
+	}
+	return false, -1, nil
+}
+
+var universalNameRE *regexp.Regexp = regexp.MustCompile("^\\\\U[0-9a-fA-F]+$")
+
+// \U0001F600 (Python, C, C++, Go)
+func IntUniversalName(arg string) (bool, rune, []byte) {
+	if !universalNameRE.MatchString(arg) { return false, -1, nil }
+	if len(arg) != 10 { return false, -1, nil }
+	return InterpretCodepoint(arg[2:], true)
+}
+
+var braceEscapeRE *regexp.Regexp = regexp.MustCompile("^\\\\u\\{[0-9a-fA-F]+\\}$")
+
+// \u{1F600} (JavaScript, Rust, Swift)
+func IntBraceEscape(arg string) (bool, rune, []byte) {
+	if !braceEscapeRE.MatchString(arg) { return false, -1, nil }
+	if len(arg) > 10 { return false, -1, nil }
+	return InterpretCodepoint(arg[3:len(arg)-1], true)
+}
+
+var perlRE *regexp.Regexp = regexp.MustCompile("^\\\\x\\{[0-9a-fA-F]+\\}$")
+
+// \x{1F600} (Perl, PCRE)
+func IntPerl(arg string) (bool, rune, []byte) {
+	if !perlRE.MatchString(arg) { return false, -1, nil }
+	return InterpretCodepoint(arg[3:len(arg)-1], true)
+}
+
+var unicodeNotationRE *regexp.Regexp = regexp.MustCompile("^[uU]\\+[0-9a-fA-F]+$")
+
+// U+1F600
+func IntUnicodeNotation(arg string) (bool, rune, []byte) {
+	if !unicodeNotationRE.MatchString(arg) { return false, -1, nil }
+	return InterpretCodepoint(arg[2:], true)
+}
+
+var hexCodepointRE *regexp.Regexp = regexp.MustCompile("^0[xX][0-9a-fA-F]+$")
+
+// 0x1F600
+func IntHexCodepoint(arg string) (bool, rune, []byte) {
+	if !hexCodepointRE.MatchString(arg) { return false, -1, nil }
+	return InterpretCodepoint(arg[2:], true)
+}
+
+var COctalRE *regexp.Regexp = regexp.MustCompile("^(\\\\[0-7]+)+$")
+
+// \303\251, each escape is a byte of the encoded character
+func IntCOctal(arg string) (bool, rune, []byte) {
+	if !COctalRE.MatchString(arg) { return false, -1, nil }
+
+	r := make([]byte, 0)
+
+	for _, segment := range strings.Split(arg[1:], "\\") {
+		if len(segment) > 3 { return false, -1, nil }
+		var n int
+		_, err := fmt.Sscanf(segment, "%o", &n)
+		if err != nil { return false, -1, nil }
+		if n > 0xff { return false, -1, nil }
+		r = append(r, byte(n))
+	}
+
+	return true, -1, r
+}
+
+var CSSRE *regexp.Regexp = regexp.MustCompile("^\\\\[0-9a-fA-F]+$")
+
+// \1F600 (the terminating space is removed with the rest of the whitespace)
+func IntCSS(arg string) (bool, rune, []byte) {
+	if !CSSRE.MatchString(arg) { return false, -1, nil }
+	if len(arg) > 7 { return false, -1, nil }
+	return InterpretCodepoint(arg[1:], true)
+}
+
+var pythonBytesRE *regexp.Regexp = regexp.MustCompile("^[bB]('.*'|\".*\")$")
+
+// b'\xc3\xa9', any character that isn't escaped is taken as an ASCII byte
+func IntPythonBytes(arg string) (bool, rune, []byte) {
+	if !pythonBytesRE.MatchString(arg) { return false, -1, nil }
+
+	body := arg[2:len(arg)-1]
+	r := make([]byte, 0)
+
+	for i := 0; i < len(body); i++ {
+		if body[i] >= 128 { return false, -1, nil }
+		if body[i] != '\\' {
+			r = append(r, body[i])
+			continue
+		}
+		i++
+		if i >= len(body) { return false, -1, nil }
+		switch body[i] {
+		case 'x':
+			// exactly two hex digits, Sscanf would stop at the first one that isn't
+			if i+3 > len(body) { return false, -1, nil }
+			n, err := strconv.ParseUint(body[i+1:i+3], 16, 8)
+			if err != nil { return false, -1, nil }
+			r = append(r, byte(n))
+			i += 2
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for (j < len(body)) && (j < i+3) && (body[j] >= '0') && (body[j] <= '7') { j++ }
+			var n int
+			_, err := fmt.Sscanf(body[i:j], "%o", &n)
+			if err != nil { return false, -1, nil }
+			if n > 0xff { return false, -1, nil }
+			r = append(r, byte(n))
+			i = j-1
+		case 'n': r = append(r, '\n')
+		case 'r': r = append(r, '\r')
+		case 't': r = append(r, '\t')
+		case '\\', '\'', '"': r = append(r, body[i])
+		default:
+			return false, -1, nil
+		}
+	}
+
+	if len(r) == 0 { return false, -1, nil }
+
+	return true, -1, r
 }
 
-var HTMLDecRE *regexp.Regexp = regexp.MustCompile("^&[0-9]+;$")
+var HTMLDecRE *regexp.Regexp = regexp.MustCompile("^&#[0-9]+;$")
 
-func IntHTMLDec(arg string) (bool, int, []byte) {
+// &#233;
+func IntHTMLDec(arg string) (bool, rune, []byte) {
 	if !HTMLDecRE.MatchString(arg) { return false, -1, nil }
-	return interpretCodepoint(arg[1:len(arg)-1], false)
+	return InterpretCodepoint(arg[2:len(arg)-1], false)
 }
 
-var HTMLHexRE *regexp.Regexp = regexp.MustCompile("^&#[0-9a-fA-F]+;$")
+var HTMLHexRE *regexp.Regexp = regexp.MustCompile("^&#[xX][0-9a-fA-F]+;$")
 
-func IntHTMLHex(arg string) (bool, int, []byte) {
+// &#xE9;
+func IntHTMLHex(arg string) (bool, rune, []byte) {
 	if !HTMLHexRE.MatchString(arg) { return false, -1, nil }
-	return interpretCodepoint(arg[2:len(arg)-1], true)
+	return InterpretCodepoint(arg[3:len(arg)-1], true)
 }
 
-var HTMLEntityRE *regexp.Regexp = regexp.MustCompile("^&[a-fA-F]+;$")
+var HTMLEntityRE *regexp.Regexp = regexp.MustCompile("^&[a-zA-Z][a-zA-Z0-9]*;$")
 
-func IntHTMLEntity(arg string) (bool, int, []byte) {
+func IntHTMLEntity(arg string) (bool, rune, []byte) {
 	if !HTMLEntityRE.MatchString(arg) { return false, -1, nil }
 	name := arg[1:len(arg)-1]
 	codepoint, ok := entityLookup[name]
@@ -76,12 +237,12 @@ func IntHTMLEntity(arg string) (bool, int, []byte) {
 
 var BytesRE *regexp.Regexp = regexp.MustCompile("^[0-9a-fA-F ]+$")
 
-func IntBytes(arg string) (bool, int, []byte) {
+func IntBytes(arg string) (bool, rune, []byte) {
 	if !BytesRE.MatchString(arg) { return false, -1, nil }
 
 	r := make([]byte, 0)
 	
-	for _, segment := range strings.Split(arg, " ", -1) {
+	for _, segment := range strings.Split(arg, " ") {
 		var n byte
 		
 		switch len(segment) {
@@ -104,3 +265,90 @@ func IntBytes(arg string) (bool, int, []byte) {
 	return true, -1, r
 }
 
+var hexStringRE *regexp.Regexp = regexp.MustCompile("^[0-9a-fA-F]+$")
+
+// c3a9
+func IntHexString(arg string) (bool, rune, []byte) {
+	if !hexStringRE.MatchString(arg) { return false, -1, nil }
+	if len(arg) % 2 != 0 { return false, -1, nil }
+
+	r := make([]byte, len(arg)/2)
+	for i := range r {
+		_, err := fmt.Sscanf(arg[2*i:2*i+2], "%x", &r[i])
+		if err != nil { return false, -1, nil }
+	}
+
+	return true, -1, r
+}
+
+var hexPrefixedBytesRE *regexp.Regexp = regexp.MustCompile("^0[xX][0-9a-fA-F]+([ ,]+0[xX][0-9a-fA-F]+)+$")
+
+// 0xc3 0xa9 or 0xc3, 0xa9 (a single 0x number is a code point)
+func IntHexPrefixedBytes(arg string) (bool, rune, []byte) {
+	if !hexPrefixedBytesRE.MatchString(arg) { return false, -1, nil }
+
+	r := make([]byte, 0)
+	for _, segment := range strings.FieldsFunc(arg, func(c rune) bool { return (c == ' ') || (c == ',') }) {
+		if len(segment) > 4 { return false, -1, nil }
+		var n byte
+		_, err := fmt.Sscanf(segment[2:], "%x", &n)
+		if err != nil { return false, -1, nil }
+		r = append(r, n)
+	}
+
+	return true, -1, r
+}
+
+var hexEscapesRE *regexp.Regexp = regexp.MustCompile("^(\\\\x[0-9a-fA-F][0-9a-fA-F])+$")
+
+// \xc3\xa9
+func IntHexEscapes(arg string) (bool, rune, []byte) {
+	if !hexEscapesRE.MatchString(arg) { return false, -1, nil }
+
+	r := make([]byte, len(arg)/4)
+	for i := range r {
+		_, err := fmt.Sscanf(arg[4*i+2:4*i+4], "%x", &r[i])
+		if err != nil { return false, -1, nil }
+	}
+
+	return true, -1, r
+}
+
+var decimalArrayRE *regexp.Regexp = regexp.MustCompile("^[\\[{(]? *-?[0-9]+( *, *-?[0-9]+)+ *[\\]})]?$")
+
+// [195, 169], negative numbers are signed bytes as printed by Java: [-61, -87]
+func IntDecimalArray(arg string) (bool, rune, []byte) {
+	if !decimalArrayRE.MatchString(arg) { return false, -1, nil }
+
+	r := make([]byte, 0)
+	for _, segment := range strings.Split(strings.Trim(arg, "[]{}() "), ",") {
+		var n int
+		_, err := fmt.Sscanf(strings.TrimSpace(segment), "%d", &n)
+		if err != nil { return false, -1, nil }
+		if (n < -128) || (n > 255) { return false, -1, nil }
+		r = append(r, byte(n))
+	}
+
+	return true, -1, r
+}
+
+var base32RE *regexp.Regexp = regexp.MustCompile("^[A-Z2-7]+=*$")
+
+func IntBase32(arg string) (bool, rune, []byte) {
+	if !base32RE.MatchString(arg) { return false, -1, nil }
+	if len(arg) % 8 != 0 { return false, -1, nil }
+	r, err := base32.StdEncoding.DecodeString(arg)
+	if (err != nil) || (len(r) == 0) { return false, -1, nil }
+	return true, -1, r
+}
+
+var base64RE *regexp.Regexp = regexp.MustCompile("^[A-Za-z0-9+/]+=*$")
+
+// w6k=, many words are valid base64 so it comes after the interpreters of notations that are easier to tell apart
+func IntBase64(arg string) (bool, rune, []byte) {
+	if !base64RE.MatchString(arg) { return false, -1, nil }
+	if len(arg) % 4 != 0 { return false, -1, nil }
+	r, err := base64.StdEncoding.DecodeString(arg)
+	if (err != nil) || (len(r) == 0) { return false, -1, nil }
+	return true, -1, r
+}
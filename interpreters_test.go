@@ -0,0 +1,188 @@
+package chade
+
+import (
+	"bytes"
+	"testing"
+)
+
+// an interpretCase expects arg to be understood as char, or as bytes when bytes isn't nil, or to be rejected
+type interpretCase struct {
+	arg string
+	ok bool
+	char rune
+	bytes []byte
+}
+
+var interpretCases = []struct {
+	interpreter string
+	cases []interpretCase
+}{
+	{ "java", []interpretCase{
+		{ "\\u00e9", true, 0xe9, nil },
+		{ "\\uD83D\\uDE00", true, 0x1f600, nil },
+		{ "\\uDE00\\uD83D", false, -1, nil },
+	} },
+	{ "universal-name", []interpretCase{
+		{ "\\U0001F600", true, 0x1f600, nil },
+		{ "\\U1F600", false, -1, nil },
+		{ "\\U00110000", false, -1, nil },
+	} },
+	{ "brace-escape", []interpretCase{
+		{ "\\u{1F600}", true, 0x1f600, nil },
+		{ "\\u{e9}", true, 0xe9, nil },
+		{ "\\u{}", false, -1, nil },
+		{ "\\u{110000}", false, -1, nil },
+	} },
+	{ "perl", []interpretCase{
+		{ "\\x{E9}", true, 0xe9, nil },
+		{ "\\x{zz}", false, -1, nil },
+	} },
+	{ "unicode-notation", []interpretCase{
+		{ "U+20AC", true, 0x20ac, nil },
+		{ "u+e9", true, 0xe9, nil },
+		{ "U+", false, -1, nil },
+		{ "U+110000", false, -1, nil },
+	} },
+	{ "codepoint-0x", []interpretCase{
+		{ "0x1F600", true, 0x1f600, nil },
+		{ "0x", false, -1, nil },
+		{ "0xg1", false, -1, nil },
+	} },
+	{ "c-octal", []interpretCase{
+		{ "\\303\\251", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "\\400", false, -1, nil },
+		{ "\\1234", false, -1, nil },
+		{ "\\8", false, -1, nil },
+	} },
+	{ "css", []interpretCase{
+		{ "\\1F600", true, 0x1f600, nil },
+		{ "\\e9", true, 0xe9, nil },
+		{ "\\1234567", false, -1, nil },
+		{ "\\g", false, -1, nil },
+	} },
+	{ "python-bytes", []interpretCase{
+		{ "b'\\xc3\\xa9'", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "b'A\\x41\\101'", true, -1, []byte("AAA") },
+		{ "b'\\x4g'", false, -1, nil },
+		{ "b'\\x4'", false, -1, nil },
+		{ "b'\\x+4'", false, -1, nil },
+	} },
+	{ "hex-string", []interpretCase{
+		{ "c3a9", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "c3a", false, -1, nil },
+	} },
+	{ "bytes-0x", []interpretCase{
+		{ "0xc3 0xa9", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "0xC3, 0xA9", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "0xc3", false, -1, nil },
+		{ "0x1c3 0xa9", false, -1, nil },
+	} },
+	{ "bytes-x", []interpretCase{
+		{ "\\xc3\\xa9", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "\\xc", false, -1, nil },
+	} },
+	{ "decimal-array", []interpretCase{
+		{ "[195, 169]", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "{-61, -87}", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "[256, 1]", false, -1, nil },
+		{ "195", false, -1, nil },
+	} },
+	{ "base32", []interpretCase{
+		{ "YOUQ====", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "YOUQ", false, -1, nil },
+	} },
+	{ "base64", []interpretCase{
+		{ "w6k=", true, -1, []byte{ 0xc3, 0xa9 } },
+		{ "w6k", false, -1, nil },
+		{ "w6k*", false, -1, nil },
+	} },
+}
+
+func TestInterpreters(t *testing.T) {
+	for _, ic := range interpretCases {
+		ic := ic
+		t.Run(ic.interpreter, func(t *testing.T) {
+			interpreter := interpreterById(ic.interpreter)
+			if interpreter == nil { t.Fatalf("no interpreter with id %s", ic.interpreter) }
+			for _, c := range ic.cases {
+				ok, char, b := interpreter.Interpret(c.arg)
+				switch {
+				case ok != c.ok:
+					t.Errorf("%s: got ok=%v, expected %v", c.arg, ok, c.ok)
+				case !ok:
+				case (c.bytes != nil) && !bytes.Equal(b, c.bytes):
+					t.Errorf("%s: got bytes % X, expected % X", c.arg, b, c.bytes)
+				case (c.bytes == nil) && (char != c.char):
+					t.Errorf("%s: got U+%04X, expected U+%04X", c.arg, char, c.char)
+				}
+			}
+		})
+	}
+}
+
+// InterpretCodepoint is also used directly by the encode API, where arg isn't checked by a regular expression first
+func TestInterpretCodepoint(t *testing.T) {
+	for _, c := range []struct {
+		arg string
+		hex bool
+		ok bool
+		char rune
+	}{
+		{ "41", true, true, 0x41 },
+		{ "10FFFF", true, true, 0x10ffff },
+		{ "65", false, true, 0x41 },
+		{ "41zz", true, false, -1 },
+		{ "65a", false, false, -1 },
+		{ "110000", true, false, -1 },
+		{ "FFFFFFFFF", true, false, -1 },
+		{ "-41", true, false, -1 },
+		{ "", true, false, -1 },
+	} {
+		ok, char, _ := InterpretCodepoint(c.arg, c.hex)
+		if (ok != c.ok) || (ok && (char != c.char)) {
+			t.Errorf("%q: got %v U+%04X, expected %v U+%04X", c.arg, ok, char, c.ok, c.char)
+		}
+	}
+}
+
+// a textCase expects arg to be understood as chars, or to be rejected if chars is empty
+type textCase struct {
+	arg string
+	chars string
+}
+
+var textCases = []struct {
+	interpreter string
+	cases []textCase
+}{
+	{ "mime-word", []textCase{
+		{ "=?ISO-8859-1?Q?Andr=E9?=", "André" },
+		{ "=?UTF-8?B?w6k=?= =?UTF-8?Q?=E2=82=AC?=", "é€" },
+		{ "=?ISO-2022-JP?B?GyRCJEYkOSRIGyhC?=", "てすと" },
+		{ "=?UTF-7?Q?Hi_+AOk-?=", "Hi é" },
+		{ "=?UTF-8?Q?=C3?=", "" },
+		{ "=?X-UNKNOWN?Q?A?=", "" },
+	} },
+}
+
+func TestTextInterpreters(t *testing.T) {
+	for _, tc := range textCases {
+		tc := tc
+		t.Run(tc.interpreter, func(t *testing.T) {
+			var interpreter TextInterpreter
+			for _, ti := range textInterpreters {
+				if ti.Id() == tc.interpreter { interpreter = ti }
+			}
+			if interpreter == nil { t.Fatalf("no text interpreter with id %s", tc.interpreter) }
+			for _, c := range tc.cases {
+				ok, chars, _ := interpreter.InterpretText(c.arg)
+				switch {
+				case ok != (c.chars != ""):
+					t.Errorf("%s: got ok=%v", c.arg, ok)
+				case ok && (string(chars) != c.chars):
+					t.Errorf("%s: got %q, expected %q", c.arg, string(chars), c.chars)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package chade
+
+import (
+	"fmt"
+	"github.com/aarzilli/chade/internal/iconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A character set that can be designated to one of the G0..G3 registers of an ISO-2022 stream
+type iso2022Set struct {
+	name string
+	width int // bytes per character
+	decode func([]byte) (bool, rune)
+}
+
+// iso2022Iconv converts the 7 bit bytes of a character by setting their high bit and prepending prefix, which
+// turns them into the EUC encoding of the same set
+func iso2022Iconv(charset string, prefix string) func([]byte) (bool, rune) {
+	return func(in []byte) (bool, rune) {
+		b := []byte(prefix)
+		for _, c := range in {
+			b = append(b, c | 0x80)
+		}
+		out, err := iconv.Conv("UTF-8", charset, string(b))
+		if (err != nil) || (len(out) == 0) { return false, -1 }
+		if utf8.RuneCountInString(out) != 1 { return false, -1 }
+		char, _ := utf8.DecodeRuneInString(out)
+		return true, char
+	}
+}
+
+func decodeISO2022ASCII(in []byte) (bool, rune) {
+	return true, rune(in[0])
+}
+
+// JIS X 0201 Roman is ASCII with a yen sign and an overline
+func decodeJISRoman(in []byte) (bool, rune) {
+	switch in[0] {
+	case 0x5c: return true, 0xa5
+	case 0x7e: return true, 0x203e
+	}
+	return true, rune(in[0])
+}
+
+func decodeJISKatakana(in []byte) (bool, rune) {
+	if (in[0] < 0x21) || (in[0] > 0x5f) { return false, -1 }
+	return true, 0xff61 + rune(in[0]) - 0x21
+}
+
+var (
+	iso2022ASCII = &iso2022Set{ "ASCII", 1, decodeISO2022ASCII }
+	iso2022JISRoman = &iso2022Set{ "JIS X 0201 Roman", 1, decodeJISRoman }
+	iso2022JISKatakana = &iso2022Set{ "JIS X 0201 Katakana", 1, decodeJISKatakana }
+	iso2022JIS1978 = &iso2022Set{ "JIS C 6226-1978", 2, iso2022Iconv("euc-jp", "") }
+	iso2022JIS0208 = &iso2022Set{ "JIS X 0208", 2, iso2022Iconv("euc-jp", "") }
+	iso2022JIS0212 = &iso2022Set{ "JIS X 0212", 2, iso2022Iconv("euc-jp", "\x8f") }
+	iso2022KSC5601 = &iso2022Set{ "KS C 5601", 2, iso2022Iconv("euc-kr", "") }
+	iso2022GB2312 = &iso2022Set{ "GB 2312", 2, iso2022Iconv("euc-cn", "") }
+	iso2022CNS1 = &iso2022Set{ "CNS 11643 plane 1", 2, iso2022Iconv("euc-tw", "") }
+	iso2022CNS2 = &iso2022Set{ "CNS 11643 plane 2", 2, iso2022Iconv("euc-tw", "\x8e\xa2") }
+)
+
+// An escape sequence that designates set to the register G<g>, escape are the bytes that follow ESC
+type iso2022Designation struct {
+	escape string
+	g int
+	set *iso2022Set
+}
+
+type iso2022Variant struct {
+	name string
+	designations []iso2022Designation
+}
+
+var iso2022JP *iso2022Variant = &iso2022Variant{ "ISO-2022-JP", []iso2022Designation{
+	iso2022Designation{ "(B", 0, iso2022ASCII },
+	iso2022Designation{ "(J", 0, iso2022JISRoman },
+	iso2022Designation{ "$@", 0, iso2022JIS1978 },
+	iso2022Designation{ "$B", 0, iso2022JIS0208 },
+	// extensions from ISO-2022-JP-1 and CP50221, common in the wild
+	iso2022Designation{ "(I", 0, iso2022JISKatakana },
+	iso2022Designation{ "$(D", 0, iso2022JIS0212 },
+} }
+
+var iso2022KR *iso2022Variant = &iso2022Variant{ "ISO-2022-KR", []iso2022Designation{
+	iso2022Designation{ "$)C", 1, iso2022KSC5601 },
+} }
+
+var iso2022CN *iso2022Variant = &iso2022Variant{ "ISO-2022-CN", []iso2022Designation{
+	iso2022Designation{ "$)A", 1, iso2022GB2312 },
+	iso2022Designation{ "$)G", 1, iso2022CNS1 },
+	iso2022Designation{ "$*H", 2, iso2022CNS2 },
+} }
+
+const (
+	ESC = 0x1b
+	SO = 0x0e
+	SI = 0x0f
+)
+
+func iso2022EscapeName(escape string) string {
+	parts := []string{ "ESC" }
+	for i := 0; i < len(escape); i++ {
+		parts = append(parts, string(escape[i]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func iso2022BytesName(in []byte) string {
+	r := make([]string, len(in))
+	for i := range in {
+		r[i] = fmt.Sprintf("%02X", in[i])
+	}
+	return strings.Join(r, " ")
+}
+
+// iso2022State is where an ISO-2022 stream is: the sets designated to the registers and the ones invoked
+type iso2022State struct {
+	v *iso2022Variant
+	g [4]*iso2022Set
+	gl int // register invoked into GL (changed by SO and SI)
+	single int // register invoked by a single shift, for the next character only
+}
+
+func (v *iso2022Variant) start() StreamDecoder {
+	s := &iso2022State{ v: v, single: -1 }
+	s.g[0] = iso2022ASCII
+	return s
+}
+
+// Step decodes the character or the escape sequence or shift at the beginning of in, the offsets of the errors
+// are relative to in
+func (s *iso2022State) Step(in []byte) (char rune, length int, shift string, err *DecodeError) {
+	switch {
+	case in[0] == ESC:
+		if (len(in) > 1) && (in[1] == 'N') {
+			if s.g[2] == nil { return -1, 2, "", decodeError(DECODE_INVALID_ESCAPE, 0, "", "ESC N (single shift 2) without a set designated to G2") }
+			s.single = 2
+			return -1, 2, fmt.Sprintf("ESC N → next character from G2 (%s)", s.g[2].name), nil
+		}
+		for _, d := range s.v.designations {
+			if strings.HasPrefix(string(in[1:]), d.escape) {
+				s.g[d.g] = d.set
+				return -1, 1 + len(d.escape), fmt.Sprintf("%s → G%d = %s", iso2022EscapeName(d.escape), d.g, d.set.name), nil
+			}
+		}
+		end := 1
+		for (end < len(in)) && (in[end] >= 0x20) && (in[end] <= 0x2f) { end++ }
+		if end < len(in) { end++ }
+		return -1, end, "", decodeError(DECODE_INVALID_ESCAPE, 0, "", "Unknown escape sequence %s for %s", iso2022EscapeName(string(in[1:end])), s.v.name)
+
+	case in[0] == SO:
+		if s.g[1] == nil { return -1, 1, "", decodeError(DECODE_INVALID_ESCAPE, 0, "", "SO (shift out) without a set designated to G1") }
+		s.gl = 1
+		return -1, 1, fmt.Sprintf("SO → G1 (%s) invoked", s.g[1].name), nil
+
+	case in[0] == SI:
+		s.gl = 0
+		return -1, 1, fmt.Sprintf("SI → G0 (%s) invoked", s.g[0].name), nil
+
+	case in[0] >= 0x80:
+		return -1, 1, "", decodeError(DECODE_INVALID_BYTE, 0, "00-7F", "Byte %02X has the high bit set, ISO-2022 is a 7 bit encoding", in[0])
+
+	case (in[0] < 0x20) || (in[0] == 0x7f):
+		// control characters are the same in every set
+		return rune(in[0]), 1, "", nil
+	}
+
+	set := s.g[s.gl]
+	if s.single >= 0 {
+		set = s.g[s.single]
+		s.single = -1
+	}
+	if set.width > len(in) {
+		return -1, len(in), "", decodeError(DECODE_TOO_SHORT, len(in), fmt.Sprintf("%d bytes", set.width), "Not enough bytes for a character of %s (needs %d)", set.name, set.width)
+	}
+	ok, char := set.decode(in[:set.width])
+	if !ok {
+		return -1, set.width, "", decodeError(DECODE_UNMAPPED, 0, "", "%s is not a character of %s", iso2022BytesName(in[:set.width]), set.name)
+	}
+	return char, set.width, "", nil
+}
+
+// an ISO-2022 stream can end in any state
+func (s *iso2022State) End() *DecodeError { return nil }
+
+// describeISO2022 writes the escape sequences and shifts of an encoded ISO-2022 string by name
+func describeISO2022(s string) string {
+	r := []string{}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ESC:
+			end := i+1
+			for (end < len(s)) && (s[end] >= 0x20) && (s[end] <= 0x2f) { end++ }
+			if end < len(s) { end++ }
+			r = append(r, iso2022EscapeName(s[i+1:end]))
+			i = end-1
+		case SO: r = append(r, "SO")
+		case SI: r = append(r, "SI")
+		default: r = append(r, fmt.Sprintf("%02X", s[i]))
+		}
+	}
+	return strings.Join(r, " ")
+}
+
+// MakeEncISO2022 shows the shortest escape-wrapped form of a character, the one generated by iconv: designation,
+// shift, character and return to the initial state
+func MakeEncISO2022(charset string) func(rune) (bool, string) {
+	return func(char rune) (bool, string) {
+		if char < 128 { return false, "" }
+		ok, out := EncodeCharset(charset, char)
+		if !ok { return false, "" }
+		return true, EncodeBytes(out) + " = " + describeISO2022(out)
+	}
+}
+
+func init() {
+	RegisterTracer("iso-2022-jp", MakeStreamTracer(iso2022JP.start))
+	RegisterTracer("iso-2022-kr", MakeStreamTracer(iso2022KR.start))
+	RegisterTracer("iso-2022-cn", MakeStreamTracer(iso2022CN.start))
+
+	RegisterStreamDecoder("iso-2022-jp", iso2022JP.start)
+	RegisterStreamDecoder("iso-2022-kr", iso2022KR.start)
+	RegisterStreamDecoder("iso-2022-cn", iso2022CN.start)
+}
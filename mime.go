@@ -0,0 +1,124 @@
+package chade
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var textInterpreters []TextInterpreter = []TextInterpreter{
+	&textInterpreterFunc{ "MIME encoded-word", "mime-word", IntMIMEWords },
+}
+
+// interpretText returns the result of the first text interpreter that understands argument, if as isn't empty
+// only the text interpreter with that id is tried
+func interpretText(argument string, as string) (string, []rune, []string) {
+	for _, interpreter := range textInterpreters {
+		if (as != "") && (interpreter.Id() != as) { continue }
+		ok, chars, decoderNames := interpreter.InterpretText(argument)
+		if ok { return interpreter.Name(), chars, decoderNames }
+	}
+	return "", nil, nil
+}
+
+// decodeQ decodes the Q encoding of RFC 2047, if underscores is false underscores are left alone and the result is
+// quoted-printable (RFC 2045)
+func decodeQ(s string, underscores bool) ([]byte, bool) {
+	r := []byte{}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '=':
+			if i == len(s)-1 {
+				// soft line break at the end of the input
+				return r, true
+			}
+			if i+3 > len(s) { return nil, false }
+			var n byte
+			if _, err := fmt.Sscanf(s[i+1:i+3], "%x", &n); err != nil { return nil, false }
+			r = append(r, n)
+			i += 2
+		case underscores && (s[i] == '_'):
+			r = append(r, ' ')
+		default:
+			r = append(r, s[i])
+		}
+	}
+	return r, true
+}
+
+var MIMEWordRE *regexp.Regexp = regexp.MustCompile("=\\?([^?]+)\\?([QqBb])\\?([^?]*)\\?=")
+var MIMEWordsRE *regexp.Regexp = regexp.MustCompile("^(=\\?[^?]+\\?[QqBb]\\?[^?]*\\?=[ \t]*)+$")
+
+// =?ISO-8859-1?Q?Andr=E9?= or =?UTF-8?B?w6k=?=, every encoded-word is decoded with the decoder of the charset
+// it declares, whitespace between adjacent encoded-words is ignored
+func IntMIMEWords(arg string) (bool, []rune, []string) {
+	if !MIMEWordsRE.MatchString(arg) { return false, nil, nil }
+
+	chars := []rune{}
+	decoderNames := []string{}
+
+	for _, word := range MIMEWordRE.FindAllStringSubmatch(arg, -1) {
+		charset, encoding, text := word[1], strings.ToUpper(word[2]), word[3]
+
+		// RFC 2231 language specification
+		if star := strings.Index(charset, "*"); star >= 0 { charset = charset[:star] }
+
+		decoder, ok := FindDecoder(charset)
+		if !ok { return false, nil, nil }
+
+		var bytes []byte
+		if encoding == "Q" {
+			bytes, ok = decodeQ(text, true)
+			if !ok { return false, nil, nil }
+		} else {
+			var err error
+			bytes, err = base64.StdEncoding.DecodeString(text)
+			if err != nil { return false, nil, nil }
+		}
+
+		// every encoded-word starts in the initial state of a stateful charset and must go back to it
+		wordChars, _, err := DecodeStream(NewStreamDecoder(decoder), bytes)
+		if err != nil { return false, nil, nil }
+		for _, char := range wordChars {
+			chars = append(chars, char)
+			decoderNames = append(decoderNames, decoder.Name())
+		}
+	}
+
+	if len(chars) == 0 { return false, nil, nil }
+
+	return true, chars, decoderNames
+}
+
+var quotedPrintableRE *regexp.Regexp = regexp.MustCompile("^([^=]*=[0-9A-Fa-f][0-9A-Fa-f])+[^=]*=?$")
+
+// =C3=A9, quoted-printable doesn't declare a charset, the bytes go through every decoder
+func IntQuotedPrintable(arg string) (bool, rune, []byte) {
+	if !quotedPrintableRE.MatchString(arg) { return false, -1, nil }
+	bytes, ok := decodeQ(arg, false)
+	if !ok || (len(bytes) == 0) { return false, -1, nil }
+	return true, -1, bytes
+}
+
+func EncMIMEQ(char rune) (bool, string) {
+	if IsSurrogate(char) { return false, "" }
+	s := string(char)
+	r := "=?UTF-8?Q?"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ((c >= 'a') && (c <= 'z')) || ((c >= 'A') && (c <= 'Z')) || ((c >= '0') && (c <= '9')) {
+			r += string(c)
+		} else if c == ' ' {
+			r += "_"
+		} else {
+			r += fmt.Sprintf("=%02X", c)
+		}
+	}
+	return true, r + "?="
+}
+
+func EncMIMEB(char rune) (bool, string) {
+	if IsSurrogate(char) { return false, "" }
+	return true, "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(string(char))) + "?="
+}
@@ -0,0 +1,165 @@
+package chade
+
+// An Interpreter understands one way of writing a character down. It returns either the code point (bytes is nil)
+// or the bytes that must be passed to the decoders.
+type Interpreter interface {
+	Name() string
+	Id() string // used to force an interpretation, for example with --as
+	Interpret(arg string) (ok bool, char rune, bytes []byte)
+}
+
+// A TextInterpreter understands arguments that stand for a whole string rather than a single character, and
+// decodes them itself. It returns the characters and, for each of them, the name of the decoder that was used.
+type TextInterpreter interface {
+	Name() string
+	Id() string
+	InterpretText(arg string) (ok bool, chars []rune, decoderNames []string)
+}
+
+// A Decoder turns the bytes of exactly one character into its code point, or explains why it can't
+type Decoder interface {
+	Name() string
+	Charset() string // name of the charset as understood by iconv, empty if iconv doesn't know it
+	Decode(in []byte) (char rune, err *DecodeError) // err is nil when in is exactly one character
+}
+
+// An Encoder writes a character down, either as the bytes of a charset or in some textual notation. Encode is for
+// showing, the bytes of the charsets are written in hexadecimal, EncodeRaw returns them.
+type Encoder interface {
+	Name() string
+	Charset() string // name of the charset as understood by iconv, empty if the encoder doesn't produce bytes or is a ByteEncoder
+	Encode(char rune) (ok bool, value string)
+}
+
+// A ByteEncoder is the Encoder of a charset that iconv doesn't know, or that is a variant of one iconv knows
+type ByteEncoder interface {
+	Encoder
+	EncodeRaw(char rune) (ok bool, out string) // the bytes of char in the charset
+}
+
+type interpreterFunc struct {
+	name string
+	id string
+	fn func(string) (bool, rune, []byte)
+}
+
+func (i *interpreterFunc) Name() string { return i.name }
+func (i *interpreterFunc) Id() string { return i.id }
+func (i *interpreterFunc) Interpret(arg string) (bool, rune, []byte) { return i.fn(arg) }
+
+type textInterpreterFunc struct {
+	name string
+	id string
+	fn func(string) (bool, []rune, []string)
+}
+
+func (i *textInterpreterFunc) Name() string { return i.name }
+func (i *textInterpreterFunc) Id() string { return i.id }
+func (i *textInterpreterFunc) InterpretText(arg string) (bool, []rune, []string) { return i.fn(arg) }
+
+type decoderFunc struct {
+	name string
+	charset string
+	fn func([]byte) (rune, *DecodeError)
+}
+
+func (d *decoderFunc) Name() string { return d.name }
+func (d *decoderFunc) Charset() string { return d.charset }
+func (d *decoderFunc) Decode(in []byte) (rune, *DecodeError) { return d.fn(in) }
+
+type encoderFunc struct {
+	name string
+	charset string
+	fn func(rune) (bool, string)
+}
+
+func (e *encoderFunc) Name() string { return e.name }
+func (e *encoderFunc) Charset() string { return e.charset }
+func (e *encoderFunc) Encode(char rune) (bool, string) { return e.fn(char) }
+
+type byteEncoderFunc struct {
+	name string
+	fn func(rune) (bool, string)
+	excludeAscii bool // Encode doesn't show ASCII characters, like MakeEncIconv
+}
+
+func (e *byteEncoderFunc) Name() string { return e.name }
+func (e *byteEncoderFunc) Charset() string { return "" }
+func (e *byteEncoderFunc) EncodeRaw(char rune) (bool, string) { return e.fn(char) }
+
+func (e *byteEncoderFunc) Encode(char rune) (bool, string) {
+	if e.excludeAscii && (char < 128) { return false, "" }
+	ok, out := e.fn(char)
+	if !ok { return false, "" }
+	return true, EncodeBytes(out)
+}
+
+func NewInterpreter(name, id string, fn func(string) (bool, rune, []byte)) Interpreter {
+	return &interpreterFunc{ name, id, fn }
+}
+
+func NewTextInterpreter(name, id string, fn func(string) (bool, []rune, []string)) TextInterpreter {
+	return &textInterpreterFunc{ name, id, fn }
+}
+
+func NewDecoder(name, charset string, fn func([]byte) (rune, *DecodeError)) Decoder {
+	return &decoderFunc{ name, charset, fn }
+}
+
+func NewEncoder(name, charset string, fn func(rune) (bool, string)) Encoder {
+	return &encoderFunc{ name, charset, fn }
+}
+
+// NewByteEncoder makes a ByteEncoder, fn returns the bytes of a character
+func NewByteEncoder(name string, fn func(rune) (bool, string)) ByteEncoder {
+	return &byteEncoderFunc{ name, fn, false }
+}
+
+// The Register functions add to the end of the tables, after the builtin entries. They aren't safe to call
+// while the pipeline is running, the place to call them is an init function.
+
+func RegisterInterpreter(interpreter Interpreter) {
+	interpreters = append(interpreters, interpreter)
+}
+
+func RegisterTextInterpreter(interpreter TextInterpreter) {
+	textInterpreters = append(textInterpreters, interpreter)
+}
+
+func RegisterDecoder(decoder Decoder) {
+	decoders = append(decoders, decoder)
+}
+
+func RegisterEncoder(encoder Encoder) {
+	encoders = append(encoders, encoder)
+}
+
+// RegisterTracer makes the decoders of charset explain, in the Traces of a Decoding, how they got to the
+// character. Stateful decoders (ISO-2022, UTF-7) use it to list their escape sequences and shifts.
+func RegisterTracer(charset string, tracer func([]byte) []string) {
+	decoderTracers[charset] = tracer
+}
+
+// RegisterStreamDecoder makes NewStreamDecoder use fn for charset, whose bytes mean different characters
+// depending on the escape sequences and shifts that come before them
+func RegisterStreamDecoder(charset string, fn func() StreamDecoder) {
+	streamDecoders[charset] = fn
+}
+
+// The accessors return copies, changing them doesn't change the tables
+
+func Interpreters() []Interpreter {
+	return append([]Interpreter{}, interpreters...)
+}
+
+func TextInterpreters() []TextInterpreter {
+	return append([]TextInterpreter{}, textInterpreters...)
+}
+
+func Decoders() []Decoder {
+	return append([]Decoder{}, decoders...)
+}
+
+func Encoders() []Encoder {
+	return append([]Encoder{}, encoders...)
+}
@@ -0,0 +1,156 @@
+package chade
+
+import (
+	"sort"
+	"strings"
+)
+
+// Report is the result of analyzing one argument, it's what the command line prints (as text or as JSON).
+// The first interpretation of the argument is embedded, so that its fields are at the top level of the JSON
+// document, all the others are in Alternatives.
+type Report struct {
+	Argument string `json:"argument"`
+	Interpretation
+	Alternatives []Interpretation `json:"alternatives"`
+}
+
+// Interpretation is one way of understanding the argument, decoded and encoded
+type Interpretation struct {
+	Interpreter string `json:"interpreter"` // comma separated names when many interpreters agree
+	Text string `json:"text"` // set when the argument stands for a string, Decodings then has one entry per character
+	Decodings []Decoding `json:"decodings"`
+	Rejections []Rejection `json:"rejections"`
+}
+
+// Decoding is one character the argument could be, Decoders is empty when the argument was directly a code point
+type Decoding struct {
+	Decoders []string `json:"decoders"`
+	Codepoint rune `json:"codepoint"`
+	Encodings []EncodingResult `json:"encodings"`
+	Unicode *UnicodeData `json:"unicode"`
+	Traces []Trace `json:"traces"`
+}
+
+// Trace is the explanation given by a stateful decoder of how it decoded the bytes
+type Trace struct {
+	Decoder string `json:"decoder"`
+	Steps []string `json:"steps"`
+}
+
+// Rejection is a decoder that couldn't decode the bytes, Reason is the message of Error
+type Rejection struct {
+	Decoder string `json:"decoder"`
+	Reason string `json:"reason"`
+	Error *DecodeError `json:"error"`
+}
+
+type decodingsByCodepoint []Decoding
+
+func (d decodingsByCodepoint) Len() int { return len(d) }
+func (d decodingsByCodepoint) Less(i, j int) bool { return d[i].Codepoint < d[j].Codepoint }
+func (d decodingsByCodepoint) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+
+func MakeDecoding(decoderNames []string, character rune, sel *Selection) Decoding {
+	var ud *UnicodeData
+	if (character >= 0) && (int(character) < len(UnicodeDataFile)) {
+		ud = UnicodeDataFile[character]
+	}
+	return Decoding{ decoderNames, character, Encode(character, sel), ud, []Trace{} }
+}
+
+func NewInterpretation(interpreter string) Interpretation {
+	return Interpretation{ Interpreter: interpreter, Decodings: []Decoding{}, Rejections: []Rejection{} }
+}
+
+// Analyze interprets, decodes and encodes argument using the decoders and encoders accepted by sel. All the
+// interpreters are tried unless as is the id of one of them. Interpreter is empty if nothing understood the
+// argument.
+func Analyze(argument string, as string, sel *Selection) *Report {
+	return analyze(argument, as, sel, true, func(in *Interpreted) bool { return true })
+}
+
+// AnalyzeBytes is Analyze limited to the interpretations of argument as bytes to decode, "C3 A9" but not "é"
+func AnalyzeBytes(argument string, as string, sel *Selection) *Report {
+	return analyze(argument, as, sel, false, func(in *Interpreted) bool { return in.Bytes != nil })
+}
+
+// AnalyzeCodepoints is Analyze limited to the interpretations of argument as a single character, "é" or "U+E9"
+// but not "C3 A9"
+func AnalyzeCodepoints(argument string, as string, sel *Selection) *Report {
+	return analyze(argument, as, sel, false, func(in *Interpreted) bool { return in.Bytes == nil })
+}
+
+// analyze only runs the text interpreters if text is true and only keeps the interpretations accepted by accept
+func analyze(argument string, as string, sel *Selection, text bool, accept func(*Interpreted) bool) *Report {
+	interpretations := []Interpretation{}
+
+	if text {
+		if name, chars, decoderNames := interpretText(argument, as); name != "" {
+			it := NewInterpretation(name)
+			it.Text = string(chars)
+			for i := range chars {
+				it.Decodings = append(it.Decodings, MakeDecoding([]string{ decoderNames[i] }, chars[i], sel))
+			}
+			interpretations = append(interpretations, it)
+		}
+	}
+
+	for _, in := range Interpret(argument, as) {
+		if !accept(in) { continue }
+		it := NewInterpretation(strings.Join(in.Interpreters, ", "))
+		if in.Bytes == nil {
+			it.Decodings = append(it.Decodings, MakeDecoding([]string{}, in.Char, sel))
+		} else {
+			AddDecodings(&it, in.Bytes, sel)
+		}
+		interpretations = append(interpretations, it)
+	}
+
+	r := &Report{ Argument: argument, Interpretation: NewInterpretation(""), Alternatives: []Interpretation{} }
+	if len(interpretations) > 0 {
+		r.Interpretation = interpretations[0]
+		r.Alternatives = interpretations[1:]
+	}
+	return r
+}
+
+// Interpretations returns the first interpretation and the alternatives together
+func (r *Report) Interpretations() []Interpretation {
+	if r.Interpreter == "" { return []Interpretation{} }
+	return append([]Interpretation{ r.Interpretation }, r.Alternatives...)
+}
+
+// AddDecodings fills the Decodings and Rejections of it with the results of decoding bytes
+func AddDecodings(it *Interpretation, bytes []byte, sel *Selection) {
+	characters, errors := Decode(bytes, sel)
+	for character, decoderNames := range characters {
+		it.Decodings = append(it.Decodings, MakeDecoding(decoderNames, character, sel))
+	}
+	sort.Sort(decodingsByCodepoint(it.Decodings))
+
+	for _, decoder := range decoders {
+		tracer, ok := decoderTracers[decoder.Charset()]
+		if !ok || !sel.AcceptsDecoder(decoder.Name()) { continue }
+		steps := tracer(bytes)
+		if len(steps) == 0 { continue }
+		for i := range it.Decodings {
+			if containsString(it.Decodings[i].Decoders, decoder.Name()) {
+				it.Decodings[i].Traces = append(it.Decodings[i].Traces, Trace{ decoder.Name(), steps })
+			}
+		}
+	}
+
+	// decoders table order, so that the output is stable
+	for _, decoder := range decoders {
+		if err, ok := errors[decoder.Name()]; ok {
+			it.Rejections = append(it.Rejections, Rejection{ decoder.Name(), err.Message, err })
+		}
+	}
+}
+
+func containsString(v []string, s string) bool {
+	for _, x := range v {
+		if x == s { return true }
+	}
+	return false
+}
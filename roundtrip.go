@@ -0,0 +1,69 @@
+package chade
+
+import (
+	"github.com/aarzilli/chade/internal/iconv"
+)
+
+const (
+	ROUNDTRIP_ENCODABLE = "encodable"
+	ROUNDTRIP_BESTFIT = "best-fit"
+	ROUNDTRIP_LOST = "lost"
+)
+
+// RoundtripChar tells what happens to char when it's stored with encoder and read back, for best-fit mappings it
+// also returns the character that is read back
+func RoundtripChar(encoder Encoder, char rune) (status string, out string, back string) {
+	if ok, out := EncodeRaw(encoder, char); ok {
+		back, ok := decodeRaw(encoder, out)
+		if ok && (back == string(char)) { return ROUNDTRIP_ENCODABLE, out, back }
+	}
+
+	// best-fit mappings are the ones of iconv
+	charset := encoder.Charset()
+	if charset == "" { return ROUNDTRIP_LOST, "", "" }
+	out, err := iconv.Conv(charset + "//TRANSLIT", "UTF-8", string(char))
+	if (err != nil) || (len(out) == 0) { return ROUNDTRIP_LOST, "", "" }
+	back, err = iconv.Conv("UTF-8", charset, out)
+	// iconv transliterates what it doesn't know to '?'
+	if (err != nil) || (back == "?") { return ROUNDTRIP_LOST, "", "" }
+	return ROUNDTRIP_BESTFIT, out, back
+}
+
+// decodeRaw reads back what EncodeRaw wrote, with iconv for the encoders of the charsets iconv knows and with the
+// decoder of the same name for the others
+func decodeRaw(encoder Encoder, in string) (string, bool) {
+	if encoder.Charset() != "" {
+		back, err := iconv.Conv("UTF-8", encoder.Charset(), in)
+		return back, err == nil
+	}
+	decoder, ok := FindDecoder(encoder.Name())
+	if !ok { return "", false }
+
+	chars, _, err := DecodeStream(NewStreamDecoder(decoder), []byte(in))
+	if err != nil { return "", false }
+	return string(chars), true
+}
+
+// SmallestCharset returns the encoder that can represent the whole of s using the fewest bytes, ties are won by
+// the one that comes first in the encoders table
+func SmallestCharset(s string) (Encoder, int, bool) {
+	var best Encoder
+	bestLen := -1
+	for _, encoder := range encoders {
+		if !ProducesBytes(encoder) { continue }
+		length := 0
+		for _, char := range s {
+			status, out, _ := RoundtripChar(encoder, char)
+			if status != ROUNDTRIP_ENCODABLE {
+				length = -1
+				break
+			}
+			length += len(out)
+		}
+		if length < 0 { continue }
+		if (bestLen < 0) || (length < bestLen) {
+			best, bestLen = encoder, length
+		}
+	}
+	return best, bestLen, bestLen >= 0
+}
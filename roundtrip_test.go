@@ -0,0 +1,77 @@
+package chade
+
+import (
+	"strings"
+	"testing"
+)
+
+// decoderForEncoder returns the decoder that reads what encoder writes, the one of the same charset or for the
+// ByteEncoders the one of the same name, nil if there's none
+func decoderForEncoder(encoder Encoder) Decoder {
+	for _, decoder := range decoders {
+		if encoder.Charset() == "" {
+			if decoder.Name() == encoder.Name() { return decoder }
+		} else if strings.ToLower(decoder.Charset()) == strings.ToLower(encoder.Charset()) {
+			return decoder
+		}
+	}
+	return nil
+}
+
+var combiningCharsets = map[string]bool{ "windows-1255": true, "windows-1258": true }
+
+// every character that can be encoded in a charset must be decoded back to itself by the decoder of the charset
+func TestEncoderDecoderRoundtrip(t *testing.T) {
+	for _, encoder := range encoders {
+		if !ProducesBytes(encoder) { continue }
+		decoder := decoderForEncoder(encoder)
+		if decoder == nil {
+			t.Errorf("%s has no decoder", encoder.Name())
+			continue
+		}
+
+		encoder := encoder
+		t.Run(encoder.Name(), func(t *testing.T) {
+			// no legacy charset goes past the BMP
+			limit := rune(0xffff)
+			if strings.HasPrefix(strings.ToLower(encoder.Charset()), "utf") { limit = codepointLimit() }
+			step := rune(1)
+			if testing.Short() { step = 17 }
+
+			for char := rune(0); char <= limit; char += step {
+				if IsSurrogate(char) { continue }
+				// iconv lets ESC, SO and SI through, but in ISO-2022 they are escapes and shifts, not characters
+				if ((char == ESC) || (char == SO) || (char == SI)) && strings.HasPrefix(encoder.Charset(), "iso-2022") { continue }
+				ok, out := EncodeRaw(encoder, char)
+				if !ok { continue }
+				back, err := decoder.Decode([]byte(out))
+				// iconv writes some precomposed characters as a base letter and a combining mark in the
+				// windows-1255 and windows-1258 charsets, they are not a single character of the charset
+				if (err != nil) && (err.Kind == DECODE_TOO_LONG) && combiningCharsets[encoder.Charset()] { continue }
+				if err != nil {
+					t.Fatalf("U+%04X encoded as %s can not be decoded by %s: %s", char, EncodeBytes(out), decoder.Name(), err)
+				}
+				if back != char {
+					// iconv also has one way mappings, U+0341 is written as the byte of U+0301 in windows-1258
+					if status, _, _ := RoundtripChar(encoder, char); status != ROUNDTRIP_ENCODABLE { continue }
+					t.Fatalf("U+%04X encoded as %s is decoded by %s as U+%04X", char, EncodeBytes(out), decoder.Name(), back)
+				}
+			}
+		})
+	}
+}
+
+func TestSmallestCharset(t *testing.T) {
+	for _, c := range []struct { s, charset string; length int }{
+		{ "abc", "ascii", 3 },
+		{ "héllo", "iso-8859-1", 5 },
+		{ "Ж", "iso-8859-5", 1 },
+	} {
+		encoder, length, ok := SmallestCharset(c.s)
+		if !ok {
+			t.Errorf("%s: no charset found", c.s)
+		} else if (encoder.Charset() != c.charset) || (length != c.length) {
+			t.Errorf("%s: got %s (%d bytes), expected %s (%d bytes)", c.s, encoder.Charset(), length, c.charset, c.length)
+		}
+	}
+}
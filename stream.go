@@ -0,0 +1,153 @@
+package chade
+
+import (
+	"strings"
+)
+
+// longest sequence of bytes used to encode a single character by any of the decoders
+const MaxCharLen = 4
+
+func normalizeCharset(charset string) string {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	charset = strings.Replace(charset, "-", "", -1)
+	return strings.Replace(charset, "_", "", -1)
+}
+
+// FindDecoder returns the decoder for charset, that can be written either as the iconv name of the charset
+// ("shift_jis", "iso-8859-1") or as the name of the decoder ("Shift-JIS", "ISO-8859-1 (latin1)", "iso-8859-1").
+// charset is a single name, "utf-8,shift_jis" isn't the name of any decoder.
+func FindDecoder(charset string) (Decoder, bool) {
+	if normalizeCharset(charset) == "" { return nil, false }
+	for _, decoder := range decoders {
+		if decoder.Charset() == "" { continue }
+		if normalizeCharset(decoder.Charset()) == normalizeCharset(charset) { return decoder, true }
+	}
+	for _, decoder := range decoders {
+		if matchName(decoder.Name(), charset) { return decoder, true }
+	}
+	return nil, false
+}
+
+// matchName tells if name is the name of a decoder or encoder, or the part of it before the description in
+// parentheses
+func matchName(name, s string) bool {
+	name, s = normalizeName(name), normalizeName(s)
+	return (name == s) || strings.HasPrefix(name, s + " (")
+}
+
+// DecodeStep decodes the character at the beginning of in, trying every length up to MaxCharLen.
+// If no length works the most useful of the errors given by the decoder is returned: the first one that is
+// about the content of the bytes rather than their number, or if there's none the error for the longest length.
+func DecodeStep(decoder Decoder, in []byte) (char rune, length int, err *DecodeError) {
+	var last *DecodeError
+
+	for length = 1; (length <= MaxCharLen) && (length <= len(in)); length++ {
+		char, err := decoder.Decode(in[:length])
+		if err == nil { return char, length, nil }
+		if (err.Kind != DECODE_TOO_SHORT) && (err.Kind != DECODE_TOO_LONG) { return -1, 0, err }
+		last = err
+	}
+
+	if last == nil { last = decodeError(DECODE_TOO_SHORT, 0, "", "No bytes to decode") }
+	return -1, 0, last
+}
+
+// InvalidLength is the number of bytes skipped after err: the first one, so that decoding resynchronizes from the
+// next, unless the sequence was broken by an invalid trail byte that could be the start of the next character
+func InvalidLength(err *DecodeError) int {
+	if (err.Kind == DECODE_INVALID_TRAIL) && (err.Offset > 1) { return err.Offset }
+	return 1
+}
+
+// longest sequence of bytes a StreamDecoder looks at to make a step: a surrogate pair in a UTF-7 base64 run
+const MaxStepLen = 6
+
+// A StreamDecoder decodes a stream a step at a time, keeping from a step to the next the state of the stateful
+// charsets (ISO-2022, UTF-7). A step is a character, or an escape sequence or shift that only changes the state
+// (char is -1), shift describes the escape sequences and shifts. in must have at least MaxStepLen bytes unless the
+// stream ends before. After an error length is the number of bytes to skip, 0 when the error is about the end of a
+// UTF-7 base64 run rather than about the byte that ends it.
+type StreamDecoder interface {
+	Step(in []byte) (char rune, length int, shift string, err *DecodeError)
+	End() *DecodeError // the stream ends, in a state where it may not be allowed to
+}
+
+// NewStreamDecoder returns a StreamDecoder in the initial state for the charset of decoder, the decoders of the
+// charsets without state are run with DecodeStep
+func NewStreamDecoder(decoder Decoder) StreamDecoder {
+	if fn, ok := streamDecoders[decoder.Charset()]; ok { return fn() }
+	return &stepDecoder{ decoder }
+}
+
+// Stateful tells if the charset of decoder has a state, that only a StreamDecoder keeps
+func Stateful(decoder Decoder) bool {
+	_, ok := streamDecoders[decoder.Charset()]
+	return ok
+}
+
+type stepDecoder struct {
+	decoder Decoder
+}
+
+func (d *stepDecoder) Step(in []byte) (rune, int, string, *DecodeError) {
+	char, length, err := DecodeStep(d.decoder, in)
+	if err != nil { return -1, InvalidLength(err), "", err }
+	return char, length, "", nil
+}
+
+func (d *stepDecoder) End() *DecodeError { return nil }
+
+// DecodeStream runs s over all of in, returning the decoded characters and the escape sequences and shifts met on
+// the way. Decoding stops at the first error, which is returned with its offset in in.
+func DecodeStream(s StreamDecoder, in []byte) (chars []rune, trace []string, err *DecodeError) {
+	for i := 0; i < len(in); {
+		char, length, shift, err := s.Step(in[i:])
+		if err != nil {
+			err.Offset += i
+			return chars, trace, err
+		}
+		if shift != "" { trace = append(trace, shift) }
+		if char >= 0 { chars = append(chars, char) }
+		i += length
+	}
+	if err := s.End(); err != nil {
+		err.Offset = len(in)
+		return chars, trace, err
+	}
+	return chars, trace, nil
+}
+
+// MakeDecStream decodes a single character with a StreamDecoder in the initial state returned by start
+func MakeDecStream(start func() StreamDecoder) func([]byte) (rune, *DecodeError) {
+	return func(in []byte) (rune, *DecodeError) {
+		chars, _, err := DecodeStream(start(), in)
+		if err != nil { return -1, err }
+		if len(chars) == 0 { return -1, decodeError(DECODE_NO_CHARACTER, 0, "", "Only escape sequences or shifts, no character") }
+		if len(chars) > 1 { return -1, decodeError(DECODE_MULTIPLE, 0, "", "More than one character encoded") }
+		return chars[0], nil
+	}
+}
+
+// MakeStreamTracer is a tracer for RegisterTracer that lists the escape sequences and shifts, and the error that
+// stops decoding
+func MakeStreamTracer(start func() StreamDecoder) func([]byte) []string {
+	return func(in []byte) []string {
+		_, trace, err := DecodeStream(start(), in)
+		if err != nil { trace = append(trace, err.Message) }
+		return trace
+	}
+}
+
+// FindEncoder is the same as FindDecoder for encoders that produce bytes
+func FindEncoder(charset string) (Encoder, bool) {
+	if normalizeCharset(charset) == "" { return nil, false }
+	for _, encoder := range encoders {
+		if encoder.Charset() == "" { continue }
+		if normalizeCharset(encoder.Charset()) == normalizeCharset(charset) { return encoder, true }
+	}
+	for _, encoder := range encoders {
+		if !ProducesBytes(encoder) { continue }
+		if matchName(encoder.Name(), charset) { return encoder, true }
+	}
+	return nil, false
+}
@@ -1,29 +1,29 @@
-package main;
+package chade
 
 import (
 	"strings"
 	"fmt"
-	"os"
 	"bufio"
 	"strconv"
+	"unicode"
 )
 
 type UnicodeData struct {
-	Name string
-	Block string
-	GeneralCategory string
-	CanonicalCombiningClass string
-	BidiClass string
-	DecompositionType string
-	DecompositionMapping string
-	NumericType string
-	NumericValue string
-	BidiMirrored string
-	Unicode1Name string
-	ISOComment string
-	SimpleUppercaseMapping string
-	SimpleLowercaseMapping string
-	SimpleTitlecaseMapping string
+	Name string `json:"name"`
+	Block string `json:"block"`
+	GeneralCategory string `json:"general_category"`
+	CanonicalCombiningClass string `json:"canonical_combining_class"`
+	BidiClass string `json:"bidi_class"`
+	DecompositionType string `json:"decomposition_type"`
+	DecompositionMapping string `json:"decomposition_mapping"`
+	NumericType string `json:"numeric_type"`
+	NumericValue string `json:"numeric_value"`
+	BidiMirrored string `json:"bidi_mirrored"`
+	Unicode1Name string `json:"unicode1_name"`
+	ISOComment string `json:"iso_comment"`
+	SimpleUppercaseMapping string `json:"simple_uppercase_mapping"`
+	SimpleLowercaseMapping string `json:"simple_lowercase_mapping"`
+	SimpleTitlecaseMapping string `json:"simple_titlecase_mapping"`
 }
 
 func (ud *UnicodeData) String() string {
@@ -48,53 +48,93 @@ func (ud *UnicodeData) String() string {
 	return r
 }
 
-func MakeFromUnicodeDataLine(line string) (int, *UnicodeData) {
-	fields := strings.Split(strings.TrimSpace(line), ";", -1)
-	var n int
-	_, err := fmt.Sscanf(fields[0], "%x", &n)
-	must(err)
+func MakeFromUnicodeDataLine(line string) (rune, *UnicodeData, error) {
+	fields := strings.Split(strings.TrimSpace(line), ";")
+	if len(fields) != 15 { return -1, nil, fmt.Errorf("%d fields instead of 15", len(fields)) }
+	n, err := parseCodepoint(fields[0])
+	if err != nil { return -1, nil, err }
+	decompositionType, decompositionMapping := splitDecomposition(fields[5])
 	return n, &UnicodeData{
 		fields[1],
 		"No_Block",
 		fields[2], // general category
 		fields[3], // canonical combining class
 		fields[4], // bidi class
-		fields[5], // decomposition
-		fields[6],
-		fields[7], // numeric
-		fields[8],
+		decompositionType,
+		decompositionMapping,
+		numericType(fields[6], fields[7], fields[8]),
+		fields[8], // numeric value, fields 6 and 7 repeat it when they are set
 		fields[9], // bidi mirrored
 		fields[10], // compat
 		fields[11],
 		fields[12], // case mappings
 		fields[13],
 		fields[14],
+	}, nil
+}
+
+// splitDecomposition splits the decomposition field, "<compat> 0020 0301", into the type in the angle brackets and
+// the mapping, decompositions without a type are canonical
+func splitDecomposition(field string) (string, string) {
+	if field == "" { return "", "" }
+	if !strings.HasPrefix(field, "<") { return "canonical", field }
+	end := strings.Index(field, ">")
+	if end < 0 { return "", field }
+	return field[1:end], strings.TrimSpace(field[end+1:])
+}
+
+// numericType is the numeric type of a character from the decimal, digit and numeric fields, the first one
+// that's set is the most specific
+func numericType(decimal, digit, numeric string) string {
+	switch {
+	case decimal != "":
+		return "decimal"
+	case digit != "":
+		return "digit"
+	case numeric != "":
+		return "numeric"
 	}
+	return ""
+}
+
+func parseCodepoint(s string) (rune, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil { return -1, err }
+	if n > unicode.MaxRune { return -1, fmt.Errorf("%X is past the last code point", n) }
+	return rune(n), nil
 }
 
-var UnicodeDataFile [0x10FFFF]*UnicodeData
+// UnicodeDataFile is indexed by code point, it's nil for the code points that aren't assigned
+var UnicodeDataFile [unicode.MaxRune+1]*UnicodeData
 
-func InitUnicodeDataUnicodeData() {
-	file, err := os.Open("UnicodeData.txt", os.O_RDONLY, 0)
-	must(err)
+func InitUnicodeDataUnicodeData() error {
+	file, err := dataFiles.Open("UnicodeData.txt")
+	if err != nil { return err }
 	defer file.Close()
 	in := bufio.NewReader(file)
 
-	lastId := 0
+	var lastId rune
 	
+	lineno := 1
 	for line, err := in.ReadString('\n'); err == nil; line, err = in.ReadString('\n') {
-		id, ud := MakeFromUnicodeDataLine(line)
-		for skipped := lastId; skipped < id; skipped++ {
-			UnicodeDataFile[skipped] = UnicodeDataFile[lastId]
+		id, ud, err := MakeFromUnicodeDataLine(line)
+		if err != nil { return fmt.Errorf("UnicodeData.txt line %d: %v", lineno, err) }
+		lineno++
+		// large ranges (CJK ideographs, hangul syllables, private use) are a <..., First> and a <..., Last> line
+		if strings.HasSuffix(ud.Name, ", Last>") {
+			for skipped := lastId; skipped < id; skipped++ {
+				UnicodeDataFile[skipped] = UnicodeDataFile[lastId]
+			}
 		}
 		UnicodeDataFile[id] = ud
 		lastId = id
 	}
+	return nil
 }
 
-func InitUnicodeDataBlocks() {
-	file, err := os.Open("Blocks.txt", os.O_RDONLY, 0)
-	must(err)
+func InitUnicodeDataBlocks() error {
+	file, err := dataFiles.Open("Blocks.txt")
+	if err != nil { return err }
 	defer file.Close()
 	in := bufio.NewReader(file)
 
@@ -103,27 +143,30 @@ func InitUnicodeDataBlocks() {
 		if len(line) == 0 { continue }
 		if line[0] == '#' { continue }
 		
-		split := strings.Split(line, ";", 2)
+		split := strings.SplitN(line, ";", 2)
 		if len(split) != 2 { continue }
 
 		therange, block := split[0], split[1]
 
 		block = strings.TrimSpace(block)
 
-		split = strings.Split(therange, "..", 2)
+		split = strings.SplitN(therange, "..", 2)
 
 		if len(split) != 2 { continue }
 
-		start, _ := strconv.Atoi(split[0])
-		end, _ := strconv.Atoi(split[1])
+		start, err := parseCodepoint(split[0])
+		if err != nil { continue }
+		end, err := parseCodepoint(split[1])
+		if err != nil { continue }
 
 		for i := start; i <= end; i++ {
-			UnicodeDataFile[i].Block = block
+			if UnicodeDataFile[i] != nil { UnicodeDataFile[i].Block = block }
 		}
 	}
+	return nil
 }
 
-func InitUnicodeData() {
-	InitUnicodeDataUnicodeData()
-	InitUnicodeDataBlocks()
+func InitUnicodeData() error {
+	if err := InitUnicodeDataUnicodeData(); err != nil { return err }
+	return InitUnicodeDataBlocks()
 }
\ No newline at end of file
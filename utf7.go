@@ -0,0 +1,161 @@
+package chade
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// UTF-7 (RFC 2152) and the modified UTF-7 used for IMAP mailbox names (RFC 3501, section 5.1.3) write non
+// ASCII characters as runs of base64 encoded UTF-16, started by a shift character and ended by '-'
+type utf7Variant struct {
+	name string
+	shift byte
+	alphabet string
+	imap bool
+}
+
+var utf7 *utf7Variant = &utf7Variant{ "UTF-7", '+', "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/", false }
+var utf7IMAP *utf7Variant = &utf7Variant{ "IMAP modified UTF-7", '&', "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+,", true }
+
+// utf7State is where a UTF-7 stream is: in a base64 run or not, and the bits of the run that aren't part of a
+// UTF-16 unit yet
+type utf7State struct {
+	v *utf7Variant
+	run bool
+	acc int
+	nbits int
+}
+
+func (v *utf7Variant) start() StreamDecoder {
+	return &utf7State{ v: v }
+}
+
+// Step decodes the character or the shift at the beginning of in, the offsets of the errors are relative to in.
+// The errors about how a base64 run ends have length 0 when the byte that ends it is a character.
+func (s *utf7State) Step(in []byte) (char rune, length int, shift string, err *DecodeError) {
+	v := s.v
+	if s.run {
+		if strings.IndexByte(v.alphabet, in[0]) >= 0 { return s.stepRun(in) }
+		err := s.endRun()
+		if in[0] == '-' {
+			if err != nil { return -1, 1, "", err }
+			return -1, 1, "- → end of the base64 run", nil
+		}
+		if (err == nil) && v.imap {
+			err = decodeError(DECODE_INVALID_ESCAPE, 0, "'-'", "The base64 run is not terminated by '-'")
+		}
+		if err != nil { return -1, 0, "", err }
+		// any other byte ends the run in UTF-7, and is a character
+	}
+
+	b := in[0]
+	if b >= 0x80 {
+		return -1, 1, "", decodeError(DECODE_INVALID_BYTE, 0, "00-7F", "Byte %02X has the high bit set, %s is a 7 bit encoding", b, v.name)
+	}
+
+	if b != v.shift {
+		if v.imap && ((b < 0x20) || (b > 0x7e)) {
+			return -1, 1, "", decodeError(DECODE_INVALID_BYTE, 0, "20-7E", "Byte %02X is not printable ASCII and must be base64 encoded in %s", b, v.name)
+		}
+		return rune(b), 1, "", nil
+	}
+
+	if (len(in) > 1) && (in[1] == '-') { return rune(v.shift), 2, fmt.Sprintf("%c- → %c", v.shift, v.shift), nil }
+	if (len(in) < 2) || (strings.IndexByte(v.alphabet, in[1]) < 0) {
+		return -1, 1, "", decodeError(DECODE_INVALID_ESCAPE, 1, "base64 or '-'", "Shift character %c is followed by neither base64 nor '-'", v.shift)
+	}
+	s.run = true
+	return -1, 1, fmt.Sprintf("%c → base64 run", v.shift), nil
+}
+
+// stepRun decodes the base64 characters at the beginning of in into the UTF-16 units of a character
+func (s *utf7State) stepRun(in []byte) (rune, int, string, *DecodeError) {
+	v := s.v
+	units := []uint16{}
+	i := 0
+	for ; (i < len(in)) && (strings.IndexByte(v.alphabet, in[i]) >= 0); i++ {
+		s.acc = (s.acc << 6) | strings.IndexByte(v.alphabet, in[i])
+		s.nbits += 6
+		if s.nbits < 16 { continue }
+		s.nbits -= 16
+		units = append(units, uint16(s.acc >> uint(s.nbits)))
+		s.acc &= (1 << uint(s.nbits)) - 1
+
+		char := rune(units[0])
+		if (len(units) == 1) && (char >= 0xd800) && (char <= 0xdbff) { continue }
+		if len(units) == 2 {
+			if (units[1] < 0xdc00) || (units[1] > 0xdfff) {
+				return -1, i+1, "", decodeError(DECODE_INVALID_SURROGATE, 0, "", "High surrogate %04X in a base64 run is not followed by a low surrogate", char)
+			}
+			char = utf16.DecodeRune(char, rune(units[1]))
+		} else if (char >= 0xdc00) && (char <= 0xdfff) {
+			return -1, i+1, "", decodeError(DECODE_INVALID_SURROGATE, 0, "", "Low surrogate %04X in a base64 run is not preceded by a high surrogate", char)
+		}
+		if v.imap && (char >= 0x20) && (char <= 0x7e) {
+			return -1, i+1, "", decodeError(DECODE_INVALID_ESCAPE, 0, "", "U+%04X is printable ASCII and must not be base64 encoded in %s", char, v.name)
+		}
+		return char, i+1, fmt.Sprintf("%s → U+%04X", in[:i+1], char), nil
+	}
+
+	// the run ends before the character does
+	nbits := s.nbits + 16*len(units)
+	s.acc, s.nbits = 0, 0
+	if len(units) > 0 {
+		return -1, i, "", decodeError(DECODE_INVALID_SURROGATE, 0, "", "High surrogate %04X at the end of a base64 run", units[0])
+	}
+	return -1, i, "", decodeError(DECODE_INVALID_ESCAPE, i-1, "", "The base64 run does not end on a UTF-16 boundary (%d bits left over)", nbits)
+}
+
+// endRun leaves the base64 run, whose last bits must be zero padding
+func (s *utf7State) endRun() *DecodeError {
+	acc := s.acc
+	s.run, s.acc, s.nbits = false, 0, 0
+	if acc != 0 { return decodeError(DECODE_INVALID_ESCAPE, 0, "", "The base64 run ends with padding bits that are not zero") }
+	return nil
+}
+
+// a UTF-7 stream can end in a base64 run, if the padding is right, an IMAP mailbox name can't
+func (s *utf7State) End() *DecodeError {
+	if !s.run { return nil }
+	if err := s.endRun(); err != nil { return err }
+	if s.v.imap { return decodeError(DECODE_INVALID_ESCAPE, 0, "'-'", "The base64 run is not terminated by '-'") }
+	return nil
+}
+
+// encode writes char as a shifted run
+func (v *utf7Variant) encode(char rune) string {
+	units := utf16.Encode([]rune{ char })
+
+	r := []byte{ v.shift }
+	acc, nbits := 0, 0
+	for _, unit := range units {
+		acc = (acc << 16) | int(unit)
+		nbits += 16
+		for nbits >= 6 {
+			nbits -= 6
+			r = append(r, v.alphabet[(acc >> uint(nbits)) & 0x3f])
+		}
+	}
+	if nbits > 0 {
+		r = append(r, v.alphabet[(acc << uint(6 - nbits)) & 0x3f])
+	}
+	return string(append(r, '-'))
+}
+
+func MakeEncUtf7(v *utf7Variant) func(rune) (bool, string) {
+	return func(char rune) (bool, string) {
+		if char < 128 { return false, "" }
+		if IsSurrogate(char) { return false, "" }
+		s := v.encode(char)
+		return true, EncodeBytes(s) + " = " + s
+	}
+}
+
+func init() {
+	RegisterTracer("utf-7", MakeStreamTracer(utf7.start))
+	RegisterTracer("utf-7-imap", MakeStreamTracer(utf7IMAP.start))
+
+	RegisterStreamDecoder("utf-7", utf7.start)
+	RegisterStreamDecoder("utf-7-imap", utf7IMAP.start)
+}